@@ -9,6 +9,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/dnswlt/kontoo/pkg/kontoo"
 )
@@ -78,6 +79,12 @@ func ProcessServe(args []string) error {
 	ledgerPath := fs.String("ledger", "./ledger.json", "Path to the ledger.json file")
 	baseDir := fs.String("base-dir", "", `Directory for static resources ("" to use embedded resources)`)
 	debugMode := fs.Bool("debug", false, "Enable debug mode (e.g. dynamic resource reload)")
+	offline := fs.Bool("offline", false, "Offline mode: make no outbound network requests (e.g. for stock quotes)")
+	fakeQuotes := fs.Bool("fake-quotes", false, "Serve fake quotes based on the ledger instead of querying Y! Finance (requires -debug)")
+	checkSymbols := fs.Duration("check-symbols", 0, "Interval at which to check that all quote service symbols still exist (e.g. 24h). 0 disables periodic checks")
+	trashRetention := fs.Duration("trash-retention", 30*24*time.Hour, "Period after which deleted ledger entries are purged from the trash. 0 keeps them forever")
+	adminSocket := fs.String("admin-socket", "", "Path of a unix socket on which to serve the admin interface (see the admin command). Empty disables it")
+	backupDir := fs.String("backup-dir", "", "Directory for ledger backups created via the admin interface (default: the ledger's directory)")
 	if err := fs.Parse(args); err != nil {
 		return fmt.Errorf("flag parse error: %w", err)
 	}
@@ -87,7 +94,27 @@ func ProcessServe(args []string) error {
 	if *debugMode && *baseDir == "" {
 		return fmt.Errorf("must specify -base-dir if -debug is true")
 	}
-	s, err := kontoo.NewServer(fmt.Sprintf("localhost:%d", *port), *ledgerPath, *baseDir)
+	var opts []kontoo.ServerOption
+	if *offline {
+		opts = append(opts, kontoo.WithOffline())
+	}
+	if *fakeQuotes {
+		if !*debugMode {
+			return fmt.Errorf("-fake-quotes can only be used if -debug is true")
+		}
+		opts = append(opts, kontoo.WithFakeQuotes())
+	}
+	if *checkSymbols > 0 {
+		opts = append(opts, kontoo.WithSymbolCheckInterval(*checkSymbols))
+	}
+	opts = append(opts, kontoo.WithTrashRetention(*trashRetention))
+	if *adminSocket != "" {
+		opts = append(opts, kontoo.WithAdminSocket(*adminSocket))
+	}
+	if *backupDir != "" {
+		opts = append(opts, kontoo.WithBackupDir(*backupDir))
+	}
+	s, err := kontoo.NewServer(fmt.Sprintf("localhost:%d", *port), *ledgerPath, *baseDir, opts...)
 	if err != nil {
 		return err
 	}
@@ -95,8 +122,42 @@ func ProcessServe(args []string) error {
 	return s.Serve()
 }
 
+func ProcessAdmin(args []string) error {
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	socket := fs.String("socket", "./kontoo.sock", "Path of the admin socket of a running server (see serve -admin-socket)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of admin:\n  admin [-socket <path>] <command> [<job>]\n\n")
+		fmt.Fprintf(fs.Output(), "Commands: %s\nJobs (for run-job): %s\n\n",
+			strings.Join(kontoo.AdminCommands, ", "), strings.Join(kontoo.AdminJobs, ", "))
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("flag parse error: %w", err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	command := fs.Arg(0)
+	req := &kontoo.AdminRequest{}
+	if command == "run-job" {
+		if fs.NArg() != 2 {
+			return fmt.Errorf("run-job requires a job name (valid jobs are [%s])", strings.Join(kontoo.AdminJobs, ", "))
+		}
+		req.Job = fs.Arg(1)
+	} else if fs.NArg() != 1 {
+		return fmt.Errorf("extraneous args: %v", strings.Join(fs.Args()[1:], " "))
+	}
+	msg, err := kontoo.NewAdminClient(*socket).Run(command, req)
+	if err != nil {
+		return err
+	}
+	fmt.Println(msg)
+	return nil
+}
+
 func main() {
-	commands := []string{"add", "serve", "import", "create"}
+	commands := []string{"add", "serve", "import", "create", "admin"}
 	if len(os.Args) == 1 {
 		fmt.Printf("Please specify a valid command: [%s]\n",
 			strings.Join(commands, ", "))
@@ -112,6 +173,8 @@ func main() {
 		err = ProcessImport(os.Args[2:])
 	case "create":
 		err = ProcessCreate(os.Args[2:])
+	case "admin":
+		err = ProcessAdmin(os.Args[2:])
 	default:
 		err = fmt.Errorf("invalid command: %q (valid values are [%s])",
 			os.Args[1], strings.Join(commands, ", "))
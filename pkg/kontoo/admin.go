@@ -0,0 +1,228 @@
+package kontoo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The admin interface is a small JSON API served on a unix socket.
+// It lets scripts and cron jobs trigger maintenance operations without
+// going through the browser-oriented handlers. Access is controlled by
+// the file permissions of the socket, which is only accessible by its owner.
+
+// AdminCommands lists the commands understood by the admin interface.
+var AdminCommands = []string{"reload", "save", "backup", "compact", "run-job"}
+
+// AdminJobs lists the jobs that can be triggered via the "run-job" admin command.
+var AdminJobs = []string{"symbolcheck", "purgetrash"}
+
+type AdminRequest struct {
+	// Only for run-job: the name of the job to run.
+	Job string `json:"job,omitempty"`
+}
+type AdminResponse struct {
+	Status  StatusCode `json:"status"`
+	Error   string     `json:"error,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+// WithAdminSocket makes the server serve the admin interface on the given unix socket.
+func WithAdminSocket(path string) ServerOption {
+	return func(s *Server) {
+		s.adminSocket = path
+	}
+}
+
+// WithBackupDir sets the directory to which the "backup" admin command writes
+// ledger backups. By default, backups are written next to the ledger file.
+func WithBackupDir(dir string) ServerOption {
+	return func(s *Server) {
+		s.backupDir = dir
+	}
+}
+
+func (s *Server) createAdminMux() *http.ServeMux {
+	mux := &http.ServeMux{}
+	mux.HandleFunc("POST /admin/{command}", jsonHandler(s.handleAdmin))
+	return mux
+}
+
+// listenAdmin creates the admin socket with owner-only permissions.
+// A stale socket file from a previous run is removed first.
+func (s *Server) listenAdmin() (net.Listener, error) {
+	if err := os.Remove(s.adminSocket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("cannot remove stale admin socket: %w", err)
+	}
+	l, err := listenUnixOwnerOnly(s.adminSocket)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on admin socket: %w", err)
+	}
+	return l, nil
+}
+
+func (s *Server) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	var req AdminRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	command := r.PathValue("command")
+	msg, err := s.runAdminCommand(command, &req)
+	if err != nil {
+		log.Printf("Admin command %q failed: %v", command, err)
+		s.jsonResponse(w, AdminResponse{
+			Status: StatusFailedPrecondition,
+			Error:  err.Error(),
+		})
+		return
+	}
+	log.Printf("Admin command %q: %s", command, msg)
+	s.jsonResponse(w, AdminResponse{
+		Status:  StatusOK,
+		Message: msg,
+	})
+}
+
+// runAdminCommand runs the given command. Commands acquire the server's lock
+// themselves, so that they do not interfere with concurrent HTTP requests.
+func (s *Server) runAdminCommand(command string, req *AdminRequest) (string, error) {
+	switch command {
+	case "reload":
+		if err := s.ReloadStore(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Reloaded %s", s.ledgerPath), nil
+	case "save":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := s.Store().Save(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Saved %s", s.ledgerPath), nil
+	case "backup":
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		path, err := s.backup()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Wrote backup to %s", path), nil
+	case "compact":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		n := s.Store().Compact()
+		if err := s.Store().Save(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Purged %d trashed entries", n), nil
+	case "run-job":
+		return s.runAdminJob(req.Job)
+	}
+	return "", fmt.Errorf("invalid command %q (valid commands are [%s])",
+		command, strings.Join(AdminCommands, ", "))
+}
+
+func (s *Server) runAdminJob(job string) (string, error) {
+	switch job {
+	case "symbolcheck":
+		if s.symbolChecker == nil {
+			return "", fmt.Errorf("quote service is not available")
+		}
+		// Don't hold the lock while talking to the quote service.
+		s.mu.RLock()
+		assets := s.Store().FindAssetsForQuoteService("YF")
+		s.mu.RUnlock()
+		results, err := s.symbolChecker.Check(assets)
+		if err != nil {
+			return "", err
+		}
+		broken := 0
+		for _, r := range results {
+			if r.NotFound {
+				broken++
+			}
+		}
+		return fmt.Sprintf("Checked %d symbols, %d not found", len(results), broken), nil
+	case "purgetrash":
+		if s.trashRetention <= 0 {
+			return "Trash retention is disabled, nothing to purge", nil
+		}
+		n, err := s.purgeExpiredTrash()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Purged %d expired entries from the trash", n), nil
+	}
+	return "", fmt.Errorf("invalid job %q (valid jobs are [%s])", job, strings.Join(AdminJobs, ", "))
+}
+
+// backup writes a timestamped copy of the ledger to the backup directory
+// and returns its path.
+func (s *Server) backup() (string, error) {
+	dir := s.backupDir
+	if dir == "" {
+		dir = filepath.Dir(s.ledgerPath)
+	}
+	base := filepath.Base(s.ledgerPath)
+	ext := filepath.Ext(base)
+	name := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(base, ext), time.Now().Format("20060102-150405"), ext)
+	path := filepath.Join(dir, name)
+	if err := s.Store().SaveTo(path); err != nil {
+		return "", fmt.Errorf("cannot write backup: %w", err)
+	}
+	return path, nil
+}
+
+// AdminClient sends commands to the admin interface of a running server.
+type AdminClient struct {
+	client *http.Client
+}
+
+func NewAdminClient(socket string) *AdminClient {
+	return &AdminClient{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+			Timeout: 5 * time.Minute,
+		},
+	}
+}
+
+// Run executes the given admin command and returns the server's message.
+func (c *AdminClient) Run(command string, req *AdminRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	// The host is ignored, requests are always sent to the socket.
+	resp, err := c.client.Post("http://kontoo/admin/"+command, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("cannot reach admin socket: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("admin request failed: %s", resp.Status)
+	}
+	var r AdminResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("invalid admin response: %w", err)
+	}
+	if r.Status != StatusOK {
+		return "", errors.New(r.Error)
+	}
+	return r.Message, nil
+}
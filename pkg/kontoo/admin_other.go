@@ -0,0 +1,13 @@
+//go:build !unix
+
+package kontoo
+
+import (
+	"net"
+)
+
+// listenUnixOwnerOnly listens on a unix socket. File permissions have no
+// effect on non-unix systems, so the socket is accessible per the default ACLs.
+func listenUnixOwnerOnly(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,117 @@
+package kontoo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAdminCommands(t *testing.T) {
+	dir := t.TempDir()
+	backupDir := filepath.Join(dir, "backups")
+	if err := os.Mkdir(backupDir, 0755); err != nil {
+		t.Fatal("Cannot create backup dir:", err)
+	}
+	socket := filepath.Join(dir, "admin.sock")
+	s := newTestServer(t, WithAdminSocket(socket), WithBackupDir(backupDir))
+	l, err := s.listenAdmin()
+	if err != nil {
+		t.Fatal("Cannot listen on admin socket:", err)
+	}
+	srv := &http.Server{Handler: s.createAdminMux()}
+	defer srv.Close()
+	go srv.Serve(l)
+	if fi, err := os.Stat(socket); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Admin socket should only be accessible by its owner: %v %v", fi.Mode(), err)
+	}
+
+	c := NewAdminClient(socket)
+	if _, err := c.Run("save", &AdminRequest{}); err != nil {
+		t.Error("save failed:", err)
+	}
+	msg, err := c.Run("backup", &AdminRequest{})
+	if err != nil {
+		t.Fatal("backup failed:", err)
+	}
+	backups, _ := filepath.Glob(filepath.Join(backupDir, "testledger-*.json"))
+	if len(backups) != 1 || !strings.Contains(msg, backups[0]) {
+		t.Errorf("Backup not found in %s: %q", backupDir, msg)
+	}
+	if err := s.Store().Delete(2); err != nil {
+		t.Fatal("Delete failed:", err)
+	}
+	if msg, err := c.Run("compact", &AdminRequest{}); err != nil || msg != "Purged 1 trashed entries" {
+		t.Errorf("compact: unexpected result %q %v", msg, err)
+	}
+	if len(s.Store().Trash()) != 0 {
+		t.Error("Trash should be empty after compact")
+	}
+	if _, err := c.Run("run-job", &AdminRequest{Job: "purgetrash"}); err != nil {
+		t.Error("purgetrash job failed:", err)
+	}
+	if _, err := c.Run("run-job", &AdminRequest{Job: "symbolcheck"}); err != nil {
+		t.Error("symbolcheck job failed:", err)
+	}
+	if _, err := c.Run("reload", &AdminRequest{}); err != nil {
+		t.Error("reload failed:", err)
+	}
+	if e := s.Store().FindEntryBySequenceNum(2); e != nil {
+		t.Error("Compacted entry should not be in the reloaded ledger")
+	}
+	if _, err := c.Run("run-job", &AdminRequest{Job: "nope"}); err == nil {
+		t.Error("Expected error for invalid job")
+	}
+	if _, err := c.Run("nope", &AdminRequest{}); err == nil {
+		t.Error("Expected error for invalid command")
+	}
+}
+
+func TestAdminCommandsConcurrentWithHandlers(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	s := newTestServer(t, WithAdminSocket(socket))
+	l, err := s.listenAdmin()
+	if err != nil {
+		t.Fatal("Cannot listen on admin socket:", err)
+	}
+	adminSrv := &http.Server{Handler: s.createAdminMux()}
+	defer adminSrv.Close()
+	go adminSrv.Serve(l)
+	srv := httptest.NewServer(s.createMux())
+	defer srv.Close()
+
+	// Run with -race to detect unsynchronized access to the store.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		c := NewAdminClient(socket)
+		for _, cmd := range []string{"reload", "compact", "save", "reload"} {
+			if _, err := c.Run(cmd, &AdminRequest{}); err != nil {
+				t.Errorf("%s failed: %v", cmd, err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 4; i++ {
+			body := strings.NewReader(fmt.Sprintf(`{"sequenceNum": 1, "pinned": %t}`, i%2 == 0))
+			resp, err := http.Post(srv.URL+"/kontoo/entries/pin", "application/json", body)
+			if err != nil {
+				t.Error("Post failed:", err)
+				return
+			}
+			resp.Body.Close()
+			if resp, err = http.Get(srv.URL + "/kontoo/ledger"); err != nil {
+				t.Error("Get failed:", err)
+				return
+			}
+			resp.Body.Close()
+		}
+	}()
+	wg.Wait()
+}
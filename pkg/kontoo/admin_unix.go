@@ -0,0 +1,18 @@
+//go:build unix
+
+package kontoo
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnixOwnerOnly listens on a unix socket that is only accessible by its owner.
+// The umask is restricted while the socket is created, so there is no window in
+// which other users could connect. The umask is process-wide, so this must only
+// be called while no other files are being created, e.g. at startup.
+func listenUnixOwnerOnly(path string) (net.Listener, error) {
+	old := syscall.Umask(0o177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
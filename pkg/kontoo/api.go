@@ -281,7 +281,26 @@ type Asset struct {
 	ExchangeTimezone string `json:",omitempty"`
 	CustomID         string `json:",omitempty"`
 	Currency         Currency
-	Comment          string `json:",omitempty"`
+	// (Optional) ID of the custodian at which the asset is held.
+	Custodian string `json:",omitempty"`
+	Comment   string `json:",omitempty"`
+}
+
+// Custodian is a bank or broker at which assets are held.
+type Custodian struct {
+	Created  time.Time
+	Modified time.Time
+	ID       string
+	Name     string
+	// Annual custody fee as a fraction of the market value held, e.g. 0.25%.
+	CustodyFeeMicros Micros `json:"CustodyFee,omitempty"`
+	// Fixed annual fee (e.g. account maintenance), in the ledger's base currency.
+	AnnualFeeMicros Micros `json:"AnnualFee,omitempty"`
+	// Depots (see LedgerEntry.Depot) held at this custodian. Positions in these
+	// depots are attributed to this custodian instead of the asset's custodian.
+	Depots  []string `json:",omitempty"`
+	Contact string   `json:",omitempty"`
+	Comment string   `json:",omitempty"`
 }
 
 //go:generate go-enum -type=EntryType -string -json -all=false
@@ -333,11 +352,96 @@ type LedgerEntry struct {
 	PriceMicros    Micros `json:"Price,omitempty"`    // Price of a single quantity of the asset. (1 * UnitValue) means 100% for prices specified in percent.
 	CostMicros     Micros `json:"Cost,omitempty"`     // Cost incurred by the transaction.
 
+	// Depot (broker account) in which the asset is held. Only used for assets
+	// that are held in several depots. Empty for the default depot.
+	Depot string `json:",omitempty"`
+
 	Comment string `json:",omitempty"`
+	// Pinned entries are listed as bookmarks.
+	Pinned bool `json:",omitempty"`
 }
 
 type LedgerHeader struct {
-	BaseCurrency Currency `json:",omitempty"`
+	BaseCurrency   Currency         `json:",omitempty"`
+	ImportProfiles []*ImportProfile `json:",omitempty"`
+	Settings       *LedgerSettings  `json:",omitempty"`
+}
+
+// LedgerSettings configure the UI and optional features per ledger.
+type LedgerSettings struct {
+	// Keys of pages to hide from the navigation bar and search, e.g. "calc" or "uploadCSV".
+	HiddenPages []string `json:",omitempty"`
+	// Additional links shown in the navigation bar.
+	CustomLinks []*NavLink `json:",omitempty"`
+	// Enables or disables features. Features not listed here use their default.
+	Features map[string]bool `json:",omitempty"`
+	// Thresholds for balance change alerts. Defaults are used if nil.
+	BalanceAlerts *BalanceAlertSettings `json:",omitempty"`
+	// Maximum relative deviation of an interest payment from the expected
+	// interest, e.g. 0.25 for 25%. The default is used if zero.
+	MaxInterestDeviation Micros `json:",omitempty"`
+	// Fixed-income assets that mature within this many months are reported
+	// as cash equivalents in allocation reports. 0 disables the reclassification.
+	CashEquivalentMonths int `json:",omitempty"`
+}
+
+// BalanceAlertSettings define when a new account balance differs so much
+// from the previous one that it must be confirmed before it is added.
+// An alert is raised only if both thresholds are exceeded; a zero
+// threshold is always exceeded.
+type BalanceAlertSettings struct {
+	// Absolute change in the account's currency, e.g. 1000.
+	MinChange Micros `json:",omitempty"`
+	// Change relative to the previous balance, e.g. 1.0 for 100%.
+	MinChangeRatio Micros `json:",omitempty"`
+}
+
+type NavLink struct {
+	Title string
+	URL   string
+}
+
+// Features that can be enabled or disabled per ledger.
+const (
+	FeatureBalanceAlerts = "balancealerts"
+	FeatureCustodians    = "custodians"
+	FeatureInterestCheck = "interestcheck"
+	FeatureRisk          = "risk"
+	FeatureSearch        = "search"
+	FeatureSymbolCheck   = "symbolcheck"
+)
+
+// defaultFeatures maps all known features to whether they are enabled by default.
+var defaultFeatures = map[string]bool{
+	FeatureBalanceAlerts: true,
+	FeatureCustodians:    true,
+	FeatureInterestCheck: true,
+	FeatureRisk:          true,
+	FeatureSearch:        true,
+	FeatureSymbolCheck:   true,
+}
+
+// defaultBalanceAlerts are used if a ledger does not define its own thresholds.
+var defaultBalanceAlerts = BalanceAlertSettings{
+	MinChange:      1000 * UnitValue,
+	MinChangeRatio: UnitValue,
+}
+
+// defaultMaxInterestDeviation is used if a ledger does not define its own.
+const defaultMaxInterestDeviation = 250 * Millis
+
+// ImportProfile holds the settings used to import a CSV file. Profiles are
+// identified by a signature of the file's header row, so that recurring exports
+// in the same format get imported with the same settings.
+type ImportProfile struct {
+	Signature string
+	Modified  time.Time
+	// Character encoding of the file, one of ImportEncodings.
+	Encoding string `json:",omitempty"`
+	// Maps CSV column headers to DepotExportFields. Uses DefaultDepotExportColumns if empty.
+	Columns map[string]string `json:",omitempty"`
+	// Assets whose quotes were selected for import last time.
+	AssetIDs []string `json:",omitempty"`
 }
 
 type AssetGroup struct {
@@ -346,9 +450,35 @@ type AssetGroup struct {
 }
 
 type Ledger struct {
-	Header  *LedgerHeader  `json:",omitempty"`
-	Assets  []*Asset       `json:",omitempty"`
-	Entries []*LedgerEntry `json:",omitempty"`
+	Header     *LedgerHeader  `json:",omitempty"`
+	Custodians []*Custodian   `json:",omitempty"`
+	Assets     []*Asset       `json:",omitempty"`
+	Entries    []*LedgerEntry `json:",omitempty"`
+	// Deleted entries that can still be restored.
+	Trash []*TrashedEntry `json:",omitempty"`
+	// The most recent bulk shift of value dates, kept so that it can be undone.
+	LastDateShift *DateShift `json:",omitempty"`
+}
+
+// TrashedEntry is a deleted ledger entry. It keeps its sequence number,
+// so that it can be restored at its original position in the ledger.
+type TrashedEntry struct {
+	Deleted time.Time
+	Entry   *LedgerEntry
+}
+
+// DateShift is a shift of the value dates of several ledger entries
+// by the same number of days.
+type DateShift struct {
+	Shifted time.Time `json:",omitempty"`
+	Days    int
+	Entries []*ShiftedEntry
+}
+
+type ShiftedEntry struct {
+	SequenceNum int64
+	OldDate     Date
+	NewDate     Date
 }
 
 const (
@@ -403,3 +533,14 @@ func (d Date) AddDays(n int) Date {
 func (t EntryType) NeedsAssetID() bool {
 	return t != ExchangeRate && t != UnspecifiedEntryType
 }
+
+// IsFlow reports whether entries of type t record a movement of money or assets,
+// as opposed to a state like a balance, holding, or price. Only the values
+// of flows can be meaningfully summed up.
+func (t EntryType) IsFlow() bool {
+	switch t {
+	case AssetPurchase, AssetSale, AccountCredit, AccountDebit, InterestPayment, DividendPayment:
+		return true
+	}
+	return false
+}
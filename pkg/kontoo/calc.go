@@ -263,6 +263,46 @@ func internalRateOfReturn(p *AssetPosition) Micros {
 	return FloatAsMicros(irr)
 }
 
+// Duration (in years) assumed for bond funds, which have no maturity date.
+const bondFundDuration = 5.0
+
+// modifiedDuration returns the approximate modified duration (in years) of
+// the fixed-income asset a at the given date, i.e. the relative change of its
+// price for a 1 percentage point change in interest rates.
+// The asset's own interest rate is used as its yield. Bond funds without
+// a maturity date are assumed to have a duration of bondFundDuration.
+// For all other assets without a maturity date, the duration is 0.
+func modifiedDuration(a *Asset, date Date) float64 {
+	if a.MaturityDate == nil {
+		if a.Type == BondExchangeTradedFund || a.Type == BondMutualFund {
+			return bondFundDuration
+		}
+		return 0
+	}
+	years := a.MaturityDate.Sub(date.Time).Hours() / 24 / 365
+	if years <= 0 {
+		return 0
+	}
+	y := a.InterestMicros.Float()
+	if a.InterestPayment != AnnualPayment || y == 0 {
+		// Single payment at maturity: Macaulay duration equals time to maturity.
+		return years / (1 + y)
+	}
+	// Annual coupons, paid on the (MM/DD) day of maturity.
+	var weighted, pv float64
+	for d := *a.MaturityDate; d.After(date.Time); d = (Date{d.AddDate(-1, 0, 0)}) {
+		t := d.Sub(date.Time).Hours() / 24 / 365
+		cf := y
+		if d.Equal(*a.MaturityDate) {
+			cf += 1 // Redemption
+		}
+		v := cf / math.Pow(1+y, t)
+		weighted += t * v
+		pv += v
+	}
+	return weighted / pv / (1 + y)
+}
+
 // Big ints used in IBAN validation.
 var (
 	bigInts36 [36]*big.Int
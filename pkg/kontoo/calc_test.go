@@ -346,3 +346,51 @@ func TestIRRWithInterest(t *testing.T) {
 		}
 	}
 }
+
+func TestModifiedDuration(t *testing.T) {
+	date := DateVal(2024, 1, 1)
+	tests := []struct {
+		name  string
+		asset *Asset
+		want  float64
+	}{
+		{
+			name:  "zero_coupon",
+			asset: &Asset{Type: GovernmentBond, MaturityDate: newDate(2029, 1, 1)},
+			want:  5.0,
+		},
+		{
+			name: "accrued",
+			asset: &Asset{Type: CorporateBond, MaturityDate: newDate(2029, 1, 1),
+				InterestMicros: 50 * Millis, InterestPayment: AccruedPayment},
+			want: 5.0 / 1.05,
+		},
+		{
+			// Par bond with 5% annual coupon, 2 years to maturity:
+			// D_mac = (1*0.05/1.05 + 2*1.05/1.05^2) / 1 = 1.952381
+			name: "annual",
+			asset: &Asset{Type: GovernmentBond, MaturityDate: newDate(2026, 1, 1),
+				InterestMicros: 50 * Millis, InterestPayment: AnnualPayment},
+			want: 1.952381 / 1.05,
+		},
+		{
+			name:  "bond_fund",
+			asset: &Asset{Type: BondExchangeTradedFund},
+			want:  bondFundDuration,
+		},
+		{
+			name:  "matured",
+			asset: &Asset{Type: GovernmentBond, MaturityDate: newDate(2023, 1, 1)},
+			want:  0,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := modifiedDuration(tc.asset, date)
+			// Allow for some leap year fuzziness.
+			if math.Abs(got-tc.want) > 0.01 {
+				t.Errorf("Wrong duration: want %.4f, got %.4f", tc.want, got)
+			}
+		})
+	}
+}
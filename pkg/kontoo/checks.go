@@ -0,0 +1,194 @@
+package kontoo
+
+import (
+	"fmt"
+	"math"
+)
+
+// BalanceChange describes a change of an account balance that exceeds
+// the ledger's balance alert thresholds.
+type BalanceChange struct {
+	AssetID      string
+	AssetName    string
+	Currency     Currency
+	Previous     Micros
+	PreviousDate Date
+	New          Micros
+}
+
+func (c *BalanceChange) String() string {
+	return fmt.Sprintf("The new balance of %s %s for %s (%s) differs a lot from the previous balance of %s %s on %s.",
+		c.New.Format("'.2"), c.Currency, c.AssetName, c.AssetID,
+		c.Previous.Format("'.2"), c.Currency, c.PreviousDate)
+}
+
+func (s *Store) balanceAlertSettings() BalanceAlertSettings {
+	if a := s.Settings().BalanceAlerts; a != nil {
+		return *a
+	}
+	return defaultBalanceAlerts
+}
+
+// UnusualBalanceChange checks if e is an AccountBalance entry of an
+// account-type asset whose value differs from the previous balance by more
+// than the ledger's alert thresholds. This guards against typos like
+// 20,000 instead of 2,000. It returns nil if the change is not unusual,
+// if there is no previous balance, or if balance alerts are disabled.
+//
+// e may be a new entry or an update of an existing one. In the latter case,
+// the existing entry is ignored when determining the previous balance.
+func (s *Store) UnusualBalanceChange(e *LedgerEntry) *BalanceChange {
+	if e.Type != AccountBalance || !s.FeatureEnabled(FeatureBalanceAlerts) {
+		return nil
+	}
+	a := s.assets[e.AssetID]
+	if a == nil && e.AssetID == "" {
+		a = s.FindAssetByRef(e.AssetRef)
+	}
+	if a == nil || !a.Type.IsAccountType() {
+		return nil
+	}
+	pos := &AssetPosition{Asset: a}
+	for _, p := range s.entries[a.ID()] {
+		if p.ValueDate.After(e.ValueDate.Time) {
+			break
+		}
+		if p.SequenceNum == e.SequenceNum {
+			continue
+		}
+		pos.Update(p)
+	}
+	if pos.LastUpdated.IsZero() {
+		// First entry for this account.
+		return nil
+	}
+	prev := pos.ValueMicros
+	delta := (e.ValueMicros - prev).Abs()
+	th := s.balanceAlertSettings()
+	if delta == 0 || delta < th.MinChange {
+		return nil
+	}
+	if prev != 0 && delta.Div(prev.Abs()) < th.MinChangeRatio {
+		return nil
+	}
+	return &BalanceChange{
+		AssetID:      a.ID(),
+		AssetName:    a.Name,
+		Currency:     a.Currency,
+		Previous:     prev,
+		PreviousDate: pos.LastUpdated,
+		New:          e.ValueMicros,
+	}
+}
+
+// InterestDeviation describes an interest payment that deviates from the
+// interest expected from the asset's interest rate by more than the
+// ledger's threshold.
+type InterestDeviation struct {
+	AssetID   string
+	AssetName string
+	Currency  Currency
+	// Start of the period for which interest was expected.
+	Since    Date
+	Expected Micros
+	Actual   Micros
+}
+
+func (d *InterestDeviation) String() string {
+	return fmt.Sprintf("The interest payment of %s %s for %s (%s) deviates a lot from the expected interest of %s %s since %s.",
+		d.Actual.Format("'.2"), d.Currency, d.AssetName, d.AssetID,
+		d.Expected.Format("'.2"), d.Currency, d.Since)
+}
+
+// ImplausibleInterest checks if e is an InterestPayment that deviates from
+// the expected interest by more than the ledger's maximum deviation. This can
+// reveal payments recorded for the wrong account or outdated interest rates.
+//
+// The expected interest is calculated from the asset's interest rate and its
+// balance (or nominal value) just before the payment, for the period since the
+// previous interest payment. If there is no previous payment, the period starts
+// at the asset's issue date or, if that is unset, at its first ledger entry.
+// It returns nil if the asset has no interest rate or the check is disabled.
+func (s *Store) ImplausibleInterest(e *LedgerEntry) *InterestDeviation {
+	if e.Type != InterestPayment || !s.FeatureEnabled(FeatureInterestCheck) {
+		return nil
+	}
+	a := s.assets[e.AssetID]
+	if a == nil && e.AssetID == "" {
+		a = s.FindAssetByRef(e.AssetRef)
+	}
+	if a == nil || a.InterestMicros <= 0 {
+		return nil
+	}
+	var since Date
+	if a.IssueDate != nil {
+		since = *a.IssueDate
+	}
+	pos := &AssetPosition{Asset: a}
+	for _, p := range s.entries[a.ID()] {
+		if !p.ValueDate.Before(e.ValueDate.Time) {
+			break
+		}
+		if p.SequenceNum == e.SequenceNum {
+			continue
+		}
+		if since.IsZero() || p.Type == InterestPayment && p.ValueDate.After(since.Time) {
+			since = p.ValueDate
+		}
+		pos.Update(p)
+	}
+	if since.IsZero() || !since.Before(e.ValueDate.Time) {
+		return nil
+	}
+	principal := pos.QuantityMicros
+	if a.Type.IsAccountType() {
+		principal = pos.ValueMicros
+	}
+	years := e.ValueDate.Sub(since.Time).Hours() / 24 / 365
+	var expected Micros
+	if a.InterestPayment == AccruedPayment {
+		expected = principal.Mul(FloatAsMicros(math.Pow(1+a.InterestMicros.Float(), years))) - principal
+	} else {
+		expected = principal.Mul(a.InterestMicros).Mul(FloatAsMicros(years))
+	}
+	if expected <= 0 {
+		return nil
+	}
+	maxDeviation := s.Settings().MaxInterestDeviation
+	if maxDeviation == 0 {
+		maxDeviation = defaultMaxInterestDeviation
+	}
+	if (e.ValueMicros - expected).Abs().Div(expected) <= maxDeviation {
+		return nil
+	}
+	return &InterestDeviation{
+		AssetID:   a.ID(),
+		AssetName: a.Name,
+		Currency:  a.Currency,
+		Since:     since,
+		Expected:  expected,
+		Actual:    e.ValueMicros,
+	}
+}
+
+// EntryWarnings runs all plausibility checks for e and returns
+// a description of each problem found. Entries with warnings should
+// only be added to the ledger after confirmation by the user.
+//
+// Updates of existing entries that keep the entry's value are not checked,
+// since the value was already confirmed (or found plausible) before.
+func (s *Store) EntryWarnings(e *LedgerEntry) []string {
+	if e.SequenceNum != 0 {
+		if old := s.FindEntryBySequenceNum(e.SequenceNum); old != nil && old.ValueMicros == e.ValueMicros {
+			return nil
+		}
+	}
+	var warnings []string
+	if c := s.UnusualBalanceChange(e); c != nil {
+		warnings = append(warnings, c.String())
+	}
+	if d := s.ImplausibleInterest(e); d != nil {
+		warnings = append(warnings, d.String())
+	}
+	return warnings
+}
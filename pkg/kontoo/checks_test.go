@@ -0,0 +1,184 @@
+package kontoo
+
+import (
+	"testing"
+)
+
+func TestUnusualBalanceChange(t *testing.T) {
+	l := &Ledger{
+		Assets: []*Asset{
+			{
+				Name:     "Savings",
+				Type:     SavingsAccount,
+				IBAN:     ibanDE100,
+				Currency: "EUR",
+			},
+			{
+				Name:         "Stock",
+				Type:         Stock,
+				TickerSymbol: "STCK",
+				Currency:     "EUR",
+			},
+		},
+	}
+	s, err := NewStore(l, "/test")
+	if err != nil {
+		t.Fatal("Could not create store", err)
+	}
+	balance := func(d Date, v Micros) *LedgerEntry {
+		return &LedgerEntry{
+			Type:        AccountBalance,
+			AssetID:     ibanDE100,
+			ValueDate:   d,
+			ValueMicros: v,
+		}
+	}
+	// No previous balance: never unusual.
+	if c := s.UnusualBalanceChange(balance(DateVal(2024, 1, 31), 1_000_000*UnitValue)); c != nil {
+		t.Errorf("Unexpected alert for first balance: %v", c)
+	}
+	if err := s.Add(balance(DateVal(2024, 1, 31), 2000*UnitValue)); err != nil {
+		t.Fatal("Cannot add entry:", err)
+	}
+	tests := []struct {
+		name  string
+		value Micros
+		want  bool
+	}{
+		{"typo", 20_000 * UnitValue, true},
+		{"halved", 1000 * UnitValue, false},
+		{"negative", -1000 * UnitValue, true},
+		{"small", 2500 * UnitValue, false},
+		{"doubled", 4000 * UnitValue, true},
+		{"unchanged", 2000 * UnitValue, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := s.UnusualBalanceChange(balance(DateVal(2024, 2, 29), tc.value))
+			if (c != nil) != tc.want {
+				t.Errorf("Want alert: %t, got %v", tc.want, c)
+			}
+			if c != nil && c.Previous != 2000*UnitValue {
+				t.Errorf("Wrong previous balance: %v", c.Previous)
+			}
+		})
+	}
+	// Balances before the first one have nothing to compare against.
+	if c := s.UnusualBalanceChange(balance(DateVal(2023, 12, 31), 20_000*UnitValue)); c != nil {
+		t.Errorf("Unexpected alert for earlier balance: %v", c)
+	}
+	// Other entry types are not checked.
+	credit := &LedgerEntry{
+		Type:        AccountCredit,
+		AssetID:     ibanDE100,
+		ValueDate:   DateVal(2024, 2, 29),
+		ValueMicros: 100_000 * UnitValue,
+	}
+	if c := s.UnusualBalanceChange(credit); c != nil {
+		t.Errorf("Unexpected alert for credit: %v", c)
+	}
+	// Custom thresholds.
+	s.ledger.Header.Settings = &LedgerSettings{
+		BalanceAlerts: &BalanceAlertSettings{MinChange: 100 * UnitValue},
+	}
+	if c := s.UnusualBalanceChange(balance(DateVal(2024, 2, 29), 2200*UnitValue)); c == nil {
+		t.Error("Expected alert with custom thresholds")
+	}
+	// Disabled feature.
+	s.ledger.Header.Settings = &LedgerSettings{
+		Features: map[string]bool{FeatureBalanceAlerts: false},
+	}
+	if c := s.UnusualBalanceChange(balance(DateVal(2024, 2, 29), 20_000*UnitValue)); c != nil {
+		t.Errorf("Unexpected alert with disabled feature: %v", c)
+	}
+}
+
+func TestImplausibleInterest(t *testing.T) {
+	l := &Ledger{
+		Assets: []*Asset{
+			{
+				Name:           "Savings",
+				Type:           SavingsAccount,
+				IBAN:           ibanDE100,
+				Currency:       "EUR",
+				InterestMicros: 20 * Millis, // 2%
+			},
+		},
+	}
+	s, err := NewStore(l, "/test")
+	if err != nil {
+		t.Fatal("Could not create store", err)
+	}
+	interest := func(d Date, v Micros) *LedgerEntry {
+		return &LedgerEntry{
+			Type:        InterestPayment,
+			AssetID:     ibanDE100,
+			ValueDate:   d,
+			ValueMicros: v,
+		}
+	}
+	// No entries yet: no period to compare against.
+	if d := s.ImplausibleInterest(interest(DateVal(2024, 1, 1), 1000*UnitValue)); d != nil {
+		t.Errorf("Unexpected warning without entries: %v", d)
+	}
+	if err := s.Add(&LedgerEntry{
+		Type:        AccountBalance,
+		AssetID:     ibanDE100,
+		ValueDate:   DateVal(2023, 1, 1),
+		ValueMicros: 10_000 * UnitValue,
+	}); err != nil {
+		t.Fatal("Cannot add entry:", err)
+	}
+	// One year at 2% on 10'000 yields 200.
+	tests := []struct {
+		name  string
+		value Micros
+		want  bool
+	}{
+		{"exact", 200 * UnitValue, false},
+		{"close", 180 * UnitValue, false},
+		{"tooHigh", 2000 * UnitValue, true},
+		{"tooLow", 20 * UnitValue, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := s.ImplausibleInterest(interest(DateVal(2024, 1, 1), tc.value))
+			if (d != nil) != tc.want {
+				t.Errorf("Want warning: %t, got %v", tc.want, d)
+			}
+		})
+	}
+	// The period starts at the previous interest payment.
+	if err := s.Add(interest(DateVal(2023, 7, 2), 100*UnitValue)); err != nil {
+		t.Fatal("Cannot add entry:", err)
+	}
+	if d := s.ImplausibleInterest(interest(DateVal(2024, 1, 1), 100*UnitValue)); d != nil {
+		t.Errorf("Unexpected warning for half-year interest: %v", d)
+	}
+	d := s.ImplausibleInterest(interest(DateVal(2024, 1, 1), 200*UnitValue))
+	if d == nil {
+		t.Fatal("Expected warning for full-year interest after half a year")
+	}
+	if want := DateVal(2023, 7, 2); d.Since != want {
+		t.Errorf("Wrong start of period: want %v, got %v", want, d.Since)
+	}
+	// Updates that keep the value are not checked again.
+	paid := interest(DateVal(2024, 1, 1), 2000*UnitValue)
+	if err := s.Add(paid); err != nil {
+		t.Fatal("Cannot add entry:", err)
+	}
+	update := *paid
+	update.Comment = "Confirmed"
+	if ws := s.EntryWarnings(&update); len(ws) > 0 {
+		t.Errorf("Unexpected warnings for update with unchanged value: %v", ws)
+	}
+	update.ValueMicros = 3000 * UnitValue
+	if ws := s.EntryWarnings(&update); len(ws) != 1 {
+		t.Errorf("Expected one warning for update with changed value, got %v", ws)
+	}
+	// Custom threshold.
+	s.ledger.Header.Settings = &LedgerSettings{MaxInterestDeviation: 2 * UnitValue}
+	if d := s.ImplausibleInterest(interest(DateVal(2024, 1, 1), 200*UnitValue)); d != nil {
+		t.Errorf("Unexpected warning with custom threshold: %v", d)
+	}
+}
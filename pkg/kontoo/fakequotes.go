@@ -0,0 +1,108 @@
+package kontoo
+
+import (
+	"fmt"
+	"time"
+)
+
+// FakeQuoteProvider is a QuoteProvider that serves fixed quotes from memory.
+type FakeQuoteProvider struct {
+	// Quotes by symbol. Exchange rates use Y! symbols like "EURUSD=X".
+	// The timestamp of returned quotes is set to the requested date.
+	Quotes map[string]*DailyQuote
+	// Exchange time zones by symbol, e.g. "America/New_York".
+	Timezones map[string]string
+	// If non-nil, all methods return this error.
+	Err error
+}
+
+// NewFakeQuoteProvider returns an empty FakeQuoteProvider.
+func NewFakeQuoteProvider() *FakeQuoteProvider {
+	return &FakeQuoteProvider{
+		Quotes:    make(map[string]*DailyQuote),
+		Timezones: make(map[string]string),
+	}
+}
+
+// NewFakeQuoteProviderFromStore returns a FakeQuoteProvider that serves the latest
+// prices and exchange rates found in the ledger for all assets with a Y! symbol.
+func NewFakeQuoteProviderFromStore(s *Store) *FakeQuoteProvider {
+	f := NewFakeQuoteProvider()
+	date := today()
+	for _, a := range s.FindAssetsForQuoteService("YF") {
+		symbol := a.QuoteServiceSymbols["YF"]
+		if price, _, ok := s.PriceAt(a.ID(), date); ok {
+			f.Quotes[symbol] = &DailyQuote{
+				Symbol:       symbol,
+				Currency:     a.Currency,
+				ClosingPrice: price,
+			}
+		}
+		if a.ExchangeTimezone != "" {
+			f.Timezones[symbol] = a.ExchangeTimezone
+		}
+	}
+	for _, c := range s.QuoteCurrencies() {
+		if rate, _, ok := s.ExchangeRateAt(c, date); ok {
+			symbol := fakeExchangeRateSymbol(s.BaseCurrency(), c)
+			f.Quotes[symbol] = &DailyQuote{
+				Symbol:       symbol,
+				Currency:     c,
+				ClosingPrice: rate,
+			}
+		}
+	}
+	return f
+}
+
+func fakeExchangeRateSymbol(baseCurrency, quoteCurrency Currency) string {
+	return fmt.Sprintf("%s%s=X", baseCurrency, quoteCurrency)
+}
+
+func (f *FakeQuoteProvider) GetDailyQuote(symbol string, date time.Time) (*DailyQuote, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	q, ok := f.Quotes[symbol]
+	if !ok {
+		return nil, fmt.Errorf("failed to fetch price history for %s: %w", symbol, ErrTickerNotFound)
+	}
+	res := *q
+	res.Timestamp = date
+	return &res, nil
+}
+
+func (f *FakeQuoteProvider) GetDailyExchangeRate(baseCurrency Currency, quoteCurrency Currency, date time.Time) (*DailyExchangeRate, error) {
+	q, err := f.GetDailyQuote(fakeExchangeRateSymbol(baseCurrency, quoteCurrency), date)
+	if err != nil {
+		return nil, err
+	}
+	return &DailyExchangeRate{
+		BaseCurrency:  baseCurrency,
+		QuoteCurrency: q.Currency,
+		Timestamp:     q.Timestamp,
+		ClosingPrice:  q.ClosingPrice,
+	}, nil
+}
+
+func (f *FakeQuoteProvider) FetchQuoteSummary(symbol string) (*YFQuoteSummaryResponse, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	tz, ok := f.Timezones[symbol]
+	if !ok {
+		return nil, ErrTickerNotFound
+	}
+	return &YFQuoteSummaryResponse{
+		QuoteSummary: &YFQuoteSummary{
+			Result: []*YFQuoteSummaryResult{
+				{
+					QuoteType: &YFQuoteType{
+						Symbol:           symbol,
+						TimeZoneFullName: tz,
+					},
+				},
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,127 @@
+package kontoo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	EncodingISO8859_15 = "ISO-8859-15"
+	EncodingUTF8       = "UTF-8"
+)
+
+// ImportEncodings are the supported character encodings of imported CSV files.
+var ImportEncodings = []string{EncodingISO8859_15, EncodingUTF8}
+
+// CSVSignature returns a signature of the header row of the CSV file data.
+// Files with identical headers have the same signature.
+func CSVSignature(data []byte) string {
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	h := sha256.Sum256(bytes.TrimRight(header, "\r"))
+	return hex.EncodeToString(h[:8])
+}
+
+// detectEncoding guesses the character encoding of data. Files that are
+// not valid UTF-8 are assumed to be ISO 8859-15, which is what the
+// typical German bank uses.
+func detectEncoding(data []byte) string {
+	if utf8.Valid(data) {
+		return EncodingUTF8
+	}
+	return EncodingISO8859_15
+}
+
+// decodeReader returns a reader that decodes r from the given encoding to UTF-8.
+func decodeReader(r io.Reader, encoding string) (io.Reader, error) {
+	switch encoding {
+	case EncodingISO8859_15:
+		return charmap.ISO8859_15.NewDecoder().Reader(r), nil
+	case EncodingUTF8:
+		return r, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding: %q", encoding)
+}
+
+// ColumnsOrDefault returns p's column mapping, or DefaultDepotExportColumns if it has none.
+func (p *ImportProfile) ColumnsOrDefault() map[string]string {
+	if len(p.Columns) == 0 {
+		return DefaultDepotExportColumns
+	}
+	return p.Columns
+}
+
+// ReadCSV reads the depot export data using the profile's settings.
+func (p *ImportProfile) ReadCSV(data []byte) ([]*DepotExportItem, error) {
+	r, err := decodeReader(bytes.NewReader(data), p.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	return ReadDepotExportCSVColumns(r, p.ColumnsOrDefault())
+}
+
+func validateImportProfile(p *ImportProfile) error {
+	if p.Signature == "" {
+		return fmt.Errorf("import profile has no signature")
+	}
+	if !slices.Contains(ImportEncodings, p.Encoding) {
+		return fmt.Errorf("unsupported encoding: %q", p.Encoding)
+	}
+	for h, f := range p.Columns {
+		if !slices.Contains(DepotExportFields, f) {
+			return fmt.Errorf("column %q is mapped to unknown field %q", h, f)
+		}
+	}
+	return nil
+}
+
+// ImportProfile returns a copy of the import profile for files with the given
+// signature, or nil if there is none.
+func (s *Store) ImportProfile(signature string) *ImportProfile {
+	for _, p := range s.ledger.Header.ImportProfiles {
+		if p.Signature == signature {
+			c := *p
+			return &c
+		}
+	}
+	return nil
+}
+
+// PutImportProfile adds p to the store or replaces the existing profile
+// with the same signature.
+func (s *Store) PutImportProfile(p *ImportProfile) error {
+	if err := validateImportProfile(p); err != nil {
+		return err
+	}
+	p.Modified = time.Now()
+	h := s.ledger.Header
+	i := slices.IndexFunc(h.ImportProfiles, func(q *ImportProfile) bool {
+		return q.Signature == p.Signature
+	})
+	if i >= 0 {
+		h.ImportProfiles[i] = p
+	} else {
+		h.ImportProfiles = append(h.ImportProfiles, p)
+	}
+	return nil
+}
+
+// RecordImportAssets remembers the IDs of the assets that were imported from
+// a file with the given signature, so they can be preselected next time.
+func (s *Store) RecordImportAssets(signature string, assetIDs []string) error {
+	p := s.ImportProfile(signature)
+	if p == nil {
+		return fmt.Errorf("no import profile for signature %q", signature)
+	}
+	ids := slices.Clone(assetIDs)
+	slices.Sort(ids)
+	p.AssetIDs = slices.Compact(ids)
+	return s.PutImportProfile(p)
+}
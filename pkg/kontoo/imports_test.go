@@ -0,0 +1,96 @@
+package kontoo
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCSVSignature(t *testing.T) {
+	a := CSVSignature([]byte("WKN;Kurs\r\n123;1,00\n"))
+	b := CSVSignature([]byte("WKN;Kurs\n456;2,00\n"))
+	c := CSVSignature([]byte("WKN;Preis\n123;1,00\n"))
+	if a != b {
+		t.Errorf("Same headers should have the same signature: %q != %q", a, b)
+	}
+	if a == c {
+		t.Errorf("Different headers should have different signatures: %q", a)
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	if got := detectEncoding([]byte("W\xe4hrung")); got != EncodingISO8859_15 {
+		t.Errorf("Wrong encoding for Latin-1 data: %q", got)
+	}
+	if got := detectEncoding([]byte("Währung")); got != EncodingUTF8 {
+		t.Errorf("Wrong encoding for UTF-8 data: %q", got)
+	}
+}
+
+func TestImportProfileReadCSV(t *testing.T) {
+	data := []byte("Nr;Menge;Ccy;Kurs;Wert;Tag\n" +
+		"710000;10;EUR;1,50;15,00;17.03.2024\n")
+	p := &ImportProfile{
+		Signature: CSVSignature(data),
+		Encoding:  EncodingUTF8,
+		Columns: map[string]string{
+			"Nr": "WKN", "Menge": "Quantity", "Ccy": "Currency",
+			"Kurs": "Price", "Wert": "Value", "Tag": "ValueDate",
+		},
+	}
+	items, err := p.ReadCSV(data)
+	if err != nil {
+		t.Fatal("ReadCSV failed:", err)
+	}
+	want := []*DepotExportItem{
+		{WKN: "710000", QuantityMicros: 10 * UnitValue, Currency: "EUR", PriceMicros: 1_500_000,
+			ValueMicros: 15 * UnitValue, ValueDate: DateVal(2024, 3, 17)},
+	}
+	if diff := cmp.Diff(want, items); diff != "" {
+		t.Errorf("Wrong items (-want +got): %s", diff)
+	}
+	// The default columns don't match.
+	p.Columns = nil
+	if _, err := p.ReadCSV(data); err == nil {
+		t.Error("Expected error for missing headers")
+	}
+}
+
+func TestStoreImportProfiles(t *testing.T) {
+	s, err := NewStore(NewLedger("EUR"), "/test")
+	if err != nil {
+		t.Fatal("Could not create store:", err)
+	}
+	if p := s.ImportProfile("abc"); p != nil {
+		t.Errorf("Expected no profile, got %v", p)
+	}
+	if err := s.PutImportProfile(&ImportProfile{Signature: "abc", Encoding: "EBCDIC"}); err == nil {
+		t.Error("Expected error for invalid encoding")
+	}
+	if err := s.PutImportProfile(&ImportProfile{Signature: "abc", Encoding: EncodingUTF8,
+		Columns: map[string]string{"X": "Unknown"}}); err == nil {
+		t.Error("Expected error for invalid column mapping")
+	}
+	if err := s.PutImportProfile(&ImportProfile{Signature: "abc", Encoding: EncodingUTF8}); err != nil {
+		t.Fatal("PutImportProfile failed:", err)
+	}
+	if err := s.RecordImportAssets("abc", []string{"B", "A", "B"}); err != nil {
+		t.Fatal("RecordImportAssets failed:", err)
+	}
+	if err := s.RecordImportAssets("xyz", []string{"A"}); err == nil {
+		t.Error("Expected error for unknown signature")
+	}
+	p := s.ImportProfile("abc")
+	if p == nil {
+		t.Fatal("Profile not found")
+	}
+	if diff := cmp.Diff([]string{"A", "B"}, p.AssetIDs); diff != "" {
+		t.Errorf("Wrong asset IDs (-want +got): %s", diff)
+	}
+	if p.Encoding != EncodingUTF8 {
+		t.Errorf("Encoding was not retained: %q", p.Encoding)
+	}
+	if n := len(s.ledger.Header.ImportProfiles); n != 1 {
+		t.Errorf("Wrong number of profiles: %d", n)
+	}
+}
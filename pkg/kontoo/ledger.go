@@ -1,11 +1,13 @@
 package kontoo
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
@@ -138,6 +140,12 @@ func (e *LedgerEntryRow) Price() Micros {
 func (e *LedgerEntryRow) Comment() string {
 	return e.E.Comment
 }
+func (e *LedgerEntryRow) Depot() string {
+	return e.E.Depot
+}
+func (e *LedgerEntryRow) Pinned() bool {
+	return e.E.Pinned
+}
 func (e *LedgerEntryRow) MarketValue() Micros {
 	return e.marketValue
 }
@@ -152,6 +160,7 @@ type Store struct {
 	ledger        *Ledger
 	path          string                      // Path to the ledger JSON.
 	assets        map[string]*Asset           // Maps the ledger's assets by ID.
+	custodians    map[string]*Custodian       // Maps the ledger's custodians by ID.
 	entries       map[string][]*LedgerEntry   // Entries by asset ID, ordered chronologically.
 	exchangeRates map[Currency][]*LedgerEntry // Exchange rates from Base Currency to other currencies, ordered chronologically
 	// Cache for already seen time zone names.
@@ -164,6 +173,53 @@ func (s *Store) BaseCurrency() Currency {
 	return s.ledger.Header.BaseCurrency
 }
 
+// Settings returns the ledger's settings. The result is never nil.
+func (s *Store) Settings() *LedgerSettings {
+	if s.ledger.Header.Settings == nil {
+		return &LedgerSettings{}
+	}
+	return s.ledger.Header.Settings
+}
+
+// FeatureEnabled reports whether the given feature is enabled for this ledger.
+func (s *Store) FeatureEnabled(feature string) bool {
+	if enabled, ok := s.Settings().Features[feature]; ok {
+		return enabled
+	}
+	return defaultFeatures[feature]
+}
+
+func validateSettings(st *LedgerSettings) error {
+	if st == nil {
+		return nil
+	}
+	for f := range st.Features {
+		if _, ok := defaultFeatures[f]; !ok {
+			return fmt.Errorf("unknown feature %q", f)
+		}
+	}
+	for _, k := range st.HiddenPages {
+		if !slices.ContainsFunc(sitePages, func(p sitePage) bool { return p.key == k }) {
+			return fmt.Errorf("unknown page %q", k)
+		}
+	}
+	if a := st.BalanceAlerts; a != nil && (a.MinChange < 0 || a.MinChangeRatio < 0) {
+		return fmt.Errorf("balance alert thresholds must not be negative")
+	}
+	if st.MaxInterestDeviation < 0 {
+		return fmt.Errorf("maximum interest deviation must not be negative")
+	}
+	if st.CashEquivalentMonths < 0 {
+		return fmt.Errorf("cash equivalent months must not be negative")
+	}
+	for _, l := range st.CustomLinks {
+		if l == nil || strings.TrimSpace(l.Title) == "" || l.URL == "" {
+			return fmt.Errorf("custom links must have a title and URL")
+		}
+	}
+	return nil
+}
+
 func (s *Store) timezone(tz string) (*time.Location, error) {
 	s.mut.Lock()
 	defer s.mut.Unlock()
@@ -321,6 +377,24 @@ func (s *Store) ExchangeRateAt(c Currency, t Date) (Micros, Date, bool) {
 	return rs[i-1].PriceMicros, rs[i-1].ValueDate, true
 }
 
+// ConvertAt converts the amount m from currency from to currency to,
+// using the exchange rates at t (see ExchangeRateAt).
+// The second return value is false if any required exchange rate is unknown.
+func (s *Store) ConvertAt(m Micros, from, to Currency, t Date) (Micros, bool) {
+	if from == to {
+		return m, true
+	}
+	fromRate, _, ok := s.ExchangeRateAt(from, t)
+	if !ok {
+		return 0, false
+	}
+	toRate, _, ok := s.ExchangeRateAt(to, t)
+	if !ok {
+		return 0, false
+	}
+	return m.Div(fromRate).Mul(toRate), true
+}
+
 func NewStore(ledger *Ledger, path string) (*Store, error) {
 	// Ensure header is non-nil to avoid nil checks elsewhere.
 	if ledger.Header == nil {
@@ -331,9 +405,26 @@ func NewStore(ledger *Ledger, path string) (*Store, error) {
 		path:          path,
 		entries:       make(map[string][]*LedgerEntry),
 		assets:        make(map[string]*Asset),
+		custodians:    make(map[string]*Custodian),
 		exchangeRates: make(map[Currency][]*LedgerEntry),
 		timezones:     make(map[string]*time.Location),
 	}
+	if err := validateSettings(ledger.Header.Settings); err != nil {
+		return nil, fmt.Errorf("invalid ledger settings: %w", err)
+	}
+	// Build custodian index. Must happen before assets are validated.
+	for _, c := range ledger.Custodians {
+		if err := validateCustodian(c); err != nil {
+			return nil, fmt.Errorf("invalid custodian: %v", err)
+		}
+		if _, found := s.custodians[c.ID]; found {
+			return nil, fmt.Errorf("duplicate ID in ledger custodians: %q", c.ID)
+		}
+		if err := s.validateCustodianDepots(c); err != nil {
+			return nil, fmt.Errorf("invalid custodian: %v", err)
+		}
+		s.custodians[c.ID] = c
+	}
 	// Build asset index.
 	for _, asset := range ledger.Assets {
 		if err := s.validateAsset(asset); err != nil {
@@ -362,6 +453,11 @@ func NewStore(ledger *Ledger, path string) (*Store, error) {
 			s.entries[e.AssetID] = append(s.entries[e.AssetID], e)
 		}
 	}
+	for _, t := range ledger.Trash {
+		if t.Entry == nil || t.Entry.SequenceNum <= 0 {
+			return nil, fmt.Errorf("invalid ledger: trashed entry without sequence number")
+		}
+	}
 	// Sort entries map values chronologically.
 	for k := range s.entries {
 		slices.SortFunc(s.entries[k], cmpLedgerEntry)
@@ -387,9 +483,12 @@ func NewStore(ledger *Ledger, path string) (*Store, error) {
 // Header must be the first entry in the file,
 // assets and entries can then be mixed arbitrarily.
 type LedgerRecord struct {
-	Header *LedgerHeader `json:",omitempty"`
-	Entry  *LedgerEntry  `json:",omitempty"`
-	Asset  *Asset        `json:",omitempty"`
+	Header    *LedgerHeader `json:",omitempty"`
+	Entry     *LedgerEntry  `json:",omitempty"`
+	Asset     *Asset        `json:",omitempty"`
+	Custodian *Custodian    `json:",omitempty"`
+	Trashed   *TrashedEntry `json:",omitempty"`
+	DateShift *DateShift    `json:",omitempty"`
 }
 
 func LoadStore(path string) (*Store, error) {
@@ -428,8 +527,14 @@ func LoadStore(path string) (*Store, error) {
 			l.Header = rec.Header
 		} else if rec.Asset != nil {
 			l.Assets = append(l.Assets, rec.Asset)
+		} else if rec.Custodian != nil {
+			l.Custodians = append(l.Custodians, rec.Custodian)
 		} else if rec.Entry != nil {
 			l.Entries = append(l.Entries, rec.Entry)
+		} else if rec.Trashed != nil {
+			l.Trash = append(l.Trash, rec.Trashed)
+		} else if rec.DateShift != nil {
+			l.LastDateShift = rec.DateShift
 		} else {
 			return nil, fmt.Errorf("invalid ledger %q: empty record", path)
 		}
@@ -437,14 +542,20 @@ func LoadStore(path string) (*Store, error) {
 }
 
 func (s *Store) Save() error {
-	f, err := os.Create(s.path)
+	return s.SaveTo(s.path)
+}
+
+// SaveTo writes the ledger to path, e.g. to create a backup.
+// Like the store's own path, path determines the file format.
+func (s *Store) SaveTo(path string) error {
+	f, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 	enc := json.NewEncoder(f)
 	enc.SetIndent("", "  ")
-	if filepath.Ext(s.path) == ".json" {
+	if filepath.Ext(path) == ".json" {
 		// Store as single record
 		return enc.Encode(s.ledger)
 	}
@@ -457,6 +568,13 @@ func (s *Store) Save() error {
 			return fmt.Errorf("failed to write header: %w", err)
 		}
 	}
+	for _, c := range l.Custodians {
+		if err := enc.Encode(LedgerRecord{
+			Custodian: c,
+		}); err != nil {
+			return fmt.Errorf("failed to write custodian: %w", err)
+		}
+	}
 	for _, a := range l.Assets {
 		if err := enc.Encode(LedgerRecord{
 			Asset: a,
@@ -471,6 +589,20 @@ func (s *Store) Save() error {
 			return fmt.Errorf("failed to write ledger entry: %w", err)
 		}
 	}
+	for _, t := range l.Trash {
+		if err := enc.Encode(LedgerRecord{
+			Trashed: t,
+		}); err != nil {
+			return fmt.Errorf("failed to write trashed entry: %w", err)
+		}
+	}
+	if l.LastDateShift != nil {
+		if err := enc.Encode(LedgerRecord{
+			DateShift: l.LastDateShift,
+		}); err != nil {
+			return fmt.Errorf("failed to write date shift: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -500,6 +632,22 @@ func (a *Asset) Category() AssetCategory {
 	return a.Type.category()
 }
 
+// ReportingCategory returns the category under which a is shown in allocation
+// reports at date. It differs from a.Category() only for fixed-income assets
+// that mature within the ledger's CashEquivalentMonths: those are reported
+// as cash equivalents, since they will soon be available as cash.
+func (s *Store) ReportingCategory(a *Asset, date Date) AssetCategory {
+	cat := a.Category()
+	months := s.Settings().CashEquivalentMonths
+	if cat != FixedIncome || months == 0 || a.MaturityDate == nil {
+		return cat
+	}
+	if a.MaturityDate.Before(date.Time.AddDate(0, months, 0)) {
+		return CashEquivalents
+	}
+	return cat
+}
+
 func (a *Asset) matchRef(ref string) bool {
 	if a.IBAN == ref || a.ISIN == ref || a.WKN == ref ||
 		a.AccountNumber == ref || a.TickerSymbol == ref ||
@@ -514,11 +662,17 @@ func (a *Asset) matchRef(ref string) bool {
 	return false
 }
 
+// nextSequenceNum returns the sequence number for a new entry.
+// Trashed entries are considered, so that they can be restored without conflicts.
 func (s *Store) nextSequenceNum() int64 {
-	if len(s.ledger.Entries) == 0 {
-		return 1
+	var maxSeq int64
+	if len(s.ledger.Entries) > 0 {
+		maxSeq = s.ledger.Entries[len(s.ledger.Entries)-1].SequenceNum
 	}
-	return s.ledger.Entries[len(s.ledger.Entries)-1].SequenceNum + 1
+	for _, t := range s.ledger.Trash {
+		maxSeq = max(maxSeq, t.Entry.SequenceNum)
+	}
+	return maxSeq + 1
 }
 
 // EntriesInRange returns all ledger entries for the given asset
@@ -588,6 +742,21 @@ func (s *Store) QuoteCurrencies() []Currency {
 	return currencies
 }
 
+// Depots returns the names of all depots used in ledger entries, in sorted order.
+func (s *Store) Depots() []string {
+	seen := make(map[string]bool)
+	var depots []string
+	for _, e := range s.ledger.Entries {
+		if e.Depot == "" || seen[e.Depot] {
+			continue
+		}
+		seen[e.Depot] = true
+		depots = append(depots, e.Depot)
+	}
+	slices.Sort(depots)
+	return depots
+}
+
 func allZero(ms ...Micros) bool {
 	for _, m := range ms {
 		if m != 0 {
@@ -606,6 +775,11 @@ func (s *Store) insert(e *LedgerEntry) {
 	}
 	e.SequenceNum = s.nextSequenceNum()
 	s.ledger.Entries = append(s.ledger.Entries, e)
+	s.index(e)
+}
+
+// index adds e to the asset-keyed or exchange rate index.
+func (s *Store) index(e *LedgerEntry) {
 	ins := func(es []*LedgerEntry, e *LedgerEntry) []*LedgerEntry {
 		l := len(es)
 		es = append(es, e)
@@ -676,6 +850,10 @@ func (s *Store) validateEntry(e *LedgerEntry) error {
 	if e.PriceMicros < 0 {
 		return fmt.Errorf("PriceMicros must not be negative")
 	}
+	if e.Depot != "" && e.Type != AssetPurchase && e.Type != AssetSale && e.Type != AssetHolding {
+		return fmt.Errorf("Depot must only be specified for %v, %v, or %v entries, not %v",
+			AssetPurchase, AssetSale, AssetHolding, e.Type)
+	}
 	// Type-specific validation
 	switch e.Type {
 	case AssetPurchase, AssetSale:
@@ -750,10 +928,66 @@ func (s *Store) Add(e *LedgerEntry) error {
 	if err := s.validateEntry(e); err != nil {
 		return fmt.Errorf("entry validation failed: %w", err)
 	}
+	if err := s.validateDepotQuantities(e); err != nil {
+		return fmt.Errorf("entry validation failed: %w", err)
+	}
 	s.insert(e)
 	return nil
 }
 
+// validateDepotQuantities checks that no sale of e's asset sells more than the
+// depot it is sold from holds, if e were added to the ledger (or replaced the
+// entry with the same sequence number). Only sales on or after e's value date
+// are checked, so that older inconsistencies do not block new entries.
+//
+// The check only applies to assets held in (non-default) depots, i.e. if e
+// or any existing entry of its asset has a Depot.
+func (s *Store) validateDepotQuantities(e *LedgerEntry) error {
+	if e.AssetID == "" {
+		return nil
+	}
+	a := s.assets[e.AssetID]
+	if a == nil || !slices.Contains(a.Type.ValidEntryTypes(), AssetSale) {
+		return nil
+	}
+	if e.Depot == "" && !slices.ContainsFunc(s.entries[e.AssetID], func(p *LedgerEntry) bool {
+		return p.Depot != ""
+	}) {
+		return nil
+	}
+	pos := &AssetPosition{Asset: a}
+	apply := func(p *LedgerEntry) error {
+		pos.Update(p)
+		if p.Type != AssetSale || p.ValueDate.Before(e.ValueDate.Time) {
+			return nil
+		}
+		if q := pos.DepotQuantity(p.Depot); q < 0 {
+			return fmt.Errorf("sale on %s sells %s more than held in depot %q",
+				p.ValueDate, (-q).Format("'"), p.Depot)
+		}
+		return nil
+	}
+	added := false
+	for _, p := range s.entries[e.AssetID] {
+		if p.SequenceNum == e.SequenceNum && e.SequenceNum != 0 {
+			continue // Replaced by e.
+		}
+		if !added && p.ValueDate.After(e.ValueDate.Time) {
+			added = true
+			if err := apply(e); err != nil {
+				return err
+			}
+		}
+		if err := apply(p); err != nil {
+			return err
+		}
+	}
+	if !added {
+		return apply(e)
+	}
+	return nil
+}
+
 // Updates replaces the ledger entry sequenceNum with the given entry e.
 // In contrast to Add, Update expects e to be entirely valid; it will only
 // lookup assets by ID, the currency must be set, etc.
@@ -768,6 +1002,9 @@ func (s *Store) Update(e *LedgerEntry) error {
 	if err := s.validateEntry(e); err != nil {
 		return fmt.Errorf("entry validation failed: %w", err)
 	}
+	if err := s.validateDepotQuantities(e); err != nil {
+		return fmt.Errorf("entry validation failed: %w", err)
+	}
 	// Overwrite existing entry's data with new entry, but update Created
 	if e.Created.IsZero() {
 		e.Created = time.Now()
@@ -776,7 +1013,22 @@ func (s *Store) Update(e *LedgerEntry) error {
 	return nil
 }
 
+// Delete moves the ledger entry with the given sequence number to the trash.
 func (s *Store) Delete(sequenceNum int64) error {
+	e, err := s.remove(sequenceNum)
+	if err != nil {
+		return err
+	}
+	s.ledger.Trash = append(s.ledger.Trash, &TrashedEntry{
+		Deleted: time.Now(),
+		Entry:   e,
+	})
+	return nil
+}
+
+// remove removes the ledger entry with the given sequence number
+// from the ledger and all indexes and returns it.
+func (s *Store) remove(sequenceNum int64) (*LedgerEntry, error) {
 	i := 0
 	es := s.ledger.Entries
 	for ; i < len(es); i++ {
@@ -785,35 +1037,266 @@ func (s *Store) Delete(sequenceNum int64) error {
 		}
 	}
 	if i == len(es) {
-		return fmt.Errorf("sequence number %d not found in ledger", sequenceNum)
-	}
-	if es[i].AssetID != "" {
-		// Delete from .entries index.
-		aes := s.entries[es[i].AssetID]
-		for j, e := range aes {
-			if e == es[i] {
-				copy(aes[j:], aes[j+1:])
-				s.entries[es[i].AssetID] = aes[:len(aes)-1]
-				break
+		return nil, fmt.Errorf("sequence number %d not found in ledger", sequenceNum)
+	}
+	removed := es[i]
+	s.unindex(removed)
+	// Delete from ledger.
+	copy(es[i:], es[i+1:])
+	s.ledger.Entries = es[:len(es)-1]
+	return removed, nil
+}
+
+// unindex removes r from the asset-keyed or exchange rate index.
+// It must use the same conditions as index to find r.
+func (s *Store) unindex(r *LedgerEntry) {
+	del := func(es []*LedgerEntry) []*LedgerEntry {
+		for j, e := range es {
+			if e == r {
+				return slices.Delete(es, j, j+1)
+			}
+		}
+		return es
+	}
+	if r.Type == ExchangeRate && r.Currency == s.ledger.Header.BaseCurrency {
+		if es, ok := s.exchangeRates[r.QuoteCurrency]; ok {
+			s.exchangeRates[r.QuoteCurrency] = del(es)
+		}
+	} else if es, ok := s.entries[r.AssetID]; ok {
+		s.entries[r.AssetID] = del(es)
+	}
+}
+
+// Bookmarks returns rows for all pinned entries, most recent value date first.
+// The rows contain no position data.
+func (s *Store) Bookmarks() []*LedgerEntryRow {
+	var res []*LedgerEntryRow
+	for _, e := range s.ledger.Entries {
+		if e.Pinned {
+			res = append(res, &LedgerEntryRow{E: e, A: s.assets[e.AssetID]})
+		}
+	}
+	slices.SortStableFunc(res, func(a, b *LedgerEntryRow) int {
+		return b.ValueDate().Compare(a.ValueDate())
+	})
+	return res
+}
+
+// SetPinned pins or unpins the ledger entry with the given sequence number.
+func (s *Store) SetPinned(sequenceNum int64, pinned bool) error {
+	e := s.FindEntryBySequenceNum(sequenceNum)
+	if e == nil {
+		return fmt.Errorf("no entry with SequenceNum %d", sequenceNum)
+	}
+	e.Pinned = pinned
+	return nil
+}
+
+// Trash returns all trashed entries, most recently deleted first.
+func (s *Store) Trash() []*TrashedEntry {
+	res := slices.Clone(s.ledger.Trash)
+	slices.SortStableFunc(res, func(a, b *TrashedEntry) int {
+		return b.Deleted.Compare(a.Deleted)
+	})
+	return res
+}
+
+func (s *Store) trashIndex(sequenceNum int64) int {
+	return slices.IndexFunc(s.ledger.Trash, func(t *TrashedEntry) bool {
+		return t.Entry.SequenceNum == sequenceNum
+	})
+}
+
+// Restore moves the trashed entry with the given sequence number
+// back into the ledger. The entry keeps its sequence number.
+func (s *Store) Restore(sequenceNum int64) error {
+	i := s.trashIndex(sequenceNum)
+	if i < 0 {
+		return fmt.Errorf("sequence number %d not found in trash", sequenceNum)
+	}
+	e := s.ledger.Trash[i].Entry
+	if err := s.validateEntry(e); err != nil {
+		return fmt.Errorf("cannot restore entry: %w", err)
+	}
+	if err := s.validateDepotQuantities(e); err != nil {
+		return fmt.Errorf("cannot restore entry: %w", err)
+	}
+	// Keep ledger entries ordered by sequence number.
+	j, found := slices.BinarySearchFunc(s.ledger.Entries, sequenceNum, func(e *LedgerEntry, seq int64) int {
+		return cmp.Compare(e.SequenceNum, seq)
+	})
+	if found {
+		return fmt.Errorf("sequence number %d is already in use", sequenceNum)
+	}
+	s.ledger.Entries = slices.Insert(s.ledger.Entries, j, e)
+	s.index(e)
+	s.ledger.Trash = slices.Delete(s.ledger.Trash, i, i+1)
+	return nil
+}
+
+// Purge permanently deletes the trashed entry with the given sequence number.
+func (s *Store) Purge(sequenceNum int64) error {
+	i := s.trashIndex(sequenceNum)
+	if i < 0 {
+		return fmt.Errorf("sequence number %d not found in trash", sequenceNum)
+	}
+	s.ledger.Trash = slices.Delete(s.ledger.Trash, i, i+1)
+	return nil
+}
+
+// PurgeTrash permanently deletes all entries that were trashed before t.
+// It returns the number of purged entries.
+func (s *Store) PurgeTrash(t time.Time) int {
+	n := len(s.ledger.Trash)
+	s.ledger.Trash = slices.DeleteFunc(s.ledger.Trash, func(e *TrashedEntry) bool {
+		return e.Deleted.Before(t)
+	})
+	return n - len(s.ledger.Trash)
+}
+
+// PlanDateShift returns the shift of the value dates of the entries with the
+// given sequence numbers by days, without modifying the ledger.
+func (s *Store) PlanDateShift(sequenceNums []int64, days int) (*DateShift, error) {
+	if days == 0 {
+		return nil, fmt.Errorf("cannot shift value dates by 0 days")
+	}
+	shift := &DateShift{Days: days}
+	for _, seq := range sequenceNums {
+		e := s.FindEntryBySequenceNum(seq)
+		if e == nil {
+			return nil, fmt.Errorf("no entry with SequenceNum %d", seq)
+		}
+		shift.Entries = append(shift.Entries, &ShiftedEntry{
+			SequenceNum: seq,
+			OldDate:     e.ValueDate,
+			NewDate:     e.ValueDate.AddDays(days),
+		})
+	}
+	return shift, nil
+}
+
+// ShiftDates shifts the value dates of the entries with the given sequence
+// numbers by days. The shift replaces any previous one as the shift that
+// can be undone by UndoDateShift.
+func (s *Store) ShiftDates(sequenceNums []int64, days int) (*DateShift, error) {
+	shift, err := s.PlanDateShift(sequenceNums, days)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ValidateDateShift(shift); err != nil {
+		return nil, err
+	}
+	for _, se := range shift.Entries {
+		s.setValueDate(s.FindEntryBySequenceNum(se.SequenceNum), se.NewDate)
+	}
+	shift.Shifted = time.Now()
+	s.ledger.LastDateShift = shift
+	return shift, nil
+}
+
+// ValidateDateShift checks that applying shift does not make any sale of the
+// affected assets sell more than is held at its new position, in total or in
+// the sale's depot. Sales that sell too much already before the shift are ignored.
+func (s *Store) ValidateDateShift(shift *DateShift) error {
+	shifted := make(map[string][]*LedgerEntry)
+	var assetIDs []string
+	for _, se := range shift.Entries {
+		e := s.FindEntryBySequenceNum(se.SequenceNum)
+		if e == nil || e.AssetID == "" {
+			continue
+		}
+		if _, ok := shifted[e.AssetID]; !ok {
+			assetIDs = append(assetIDs, e.AssetID)
+		}
+		c := *e
+		c.ValueDate = se.NewDate
+		shifted[e.AssetID] = append(shifted[e.AssetID], &c)
+	}
+	for _, id := range assetIDs {
+		a := s.assets[id]
+		// Rebuild the asset's entries in the order setValueDate would produce.
+		var es []*LedgerEntry
+		for _, e := range s.entries[id] {
+			if !slices.ContainsFunc(shifted[id], func(c *LedgerEntry) bool { return c.SequenceNum == e.SequenceNum }) {
+				es = append(es, e)
 			}
 		}
-	} else if es[i].Type == ExchangeRate {
-		// Delete from .exchangeRates index.
-		qes := s.exchangeRates[es[i].QuoteCurrency]
-		for j, e := range qes {
-			if e == es[i] {
-				copy(qes[j:], qes[j+1:])
-				s.exchangeRates[es[i].QuoteCurrency] = qes[:len(qes)-1]
-				break
+		for _, c := range shifted[id] {
+			i := sort.Search(len(es), func(i int) bool { return es[i].ValueDate.After(c.ValueDate.Time) })
+			es = slices.Insert(es, i, c)
+		}
+		before := oversoldSales(a, s.entries[id])
+		for _, e := range oversoldSales(a, es) {
+			if !slices.ContainsFunc(before, func(b *LedgerEntry) bool { return b.SequenceNum == e.SequenceNum }) {
+				return fmt.Errorf("shift would make sale #%d of %s on %s sell more than held", e.SequenceNum, id, e.ValueDate)
 			}
 		}
 	}
-	// Delete from ledger.
-	copy(es[i:], es[i+1:])
-	s.ledger.Entries = es[:len(es)-1]
 	return nil
 }
 
+// oversoldSales returns the sales among the chronologically ordered entries
+// of asset a that sell more than is held at that point in their depot.
+func oversoldSales(a *Asset, entries []*LedgerEntry) []*LedgerEntry {
+	var res []*LedgerEntry
+	pos := &AssetPosition{Asset: a}
+	for _, e := range entries {
+		pos.Update(e)
+		if e.Type == AssetSale && pos.DepotQuantity(e.Depot) < 0 {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// LastDateShift returns the most recent date shift, or nil if there is none
+// (or it was undone already).
+func (s *Store) LastDateShift() *DateShift {
+	return s.ledger.LastDateShift
+}
+
+// UndoDateShift restores the value dates changed by the most recent date shift.
+// It fails if any of the shifted entries was deleted or got a different
+// value date in the meantime.
+func (s *Store) UndoDateShift() (*DateShift, error) {
+	shift := s.ledger.LastDateShift
+	if shift == nil {
+		return nil, fmt.Errorf("no date shift to undo")
+	}
+	entries := make([]*LedgerEntry, len(shift.Entries))
+	for i, se := range shift.Entries {
+		e := s.FindEntryBySequenceNum(se.SequenceNum)
+		if e == nil {
+			return nil, fmt.Errorf("entry %d no longer exists", se.SequenceNum)
+		}
+		if !e.ValueDate.Equal(se.NewDate) {
+			return nil, fmt.Errorf("value date of entry %d was changed after the shift", se.SequenceNum)
+		}
+		entries[i] = e
+	}
+	for i, se := range shift.Entries {
+		s.setValueDate(entries[i], se.OldDate)
+	}
+	s.ledger.LastDateShift = nil
+	return shift, nil
+}
+
+// setValueDate changes the value date of e and keeps the indexes in order.
+func (s *Store) setValueDate(e *LedgerEntry, d Date) {
+	s.unindex(e)
+	e.ValueDate = d
+	s.index(e)
+}
+
+// Compact permanently deletes all trashed entries and the undo information
+// of the last date shift. It returns the number of purged entries.
+func (s *Store) Compact() int {
+	n := len(s.ledger.Trash)
+	s.ledger.Trash = nil
+	s.ledger.LastDateShift = nil
+	return n
+}
+
 func (s *Store) validateAsset(a *Asset) error {
 	id := a.ID()
 	if id == "" {
@@ -856,6 +1339,11 @@ func (s *Store) validateAsset(a *Asset) error {
 			return fmt.Errorf("invalid ExchangeTimezone: %v", err)
 		}
 	}
+	if a.Custodian != "" {
+		if _, ok := s.custodians[a.Custodian]; !ok {
+			return fmt.Errorf("no custodian with ID %q", a.Custodian)
+		}
+	}
 	return nil
 }
 
@@ -904,6 +1392,118 @@ func (s *Store) UpdateAsset(assetID string, a *Asset) error {
 	return nil
 }
 
+func validateCustodian(c *Custodian) error {
+	if strings.TrimSpace(c.ID) == "" {
+		return fmt.Errorf("Custodian must have an ID")
+	}
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("Custodian name must not be empty")
+	}
+	if c.CustodyFeeMicros < 0 || c.AnnualFeeMicros < 0 {
+		return fmt.Errorf("Custodian fees must not be negative")
+	}
+	for i, d := range c.Depots {
+		if strings.TrimSpace(d) == "" {
+			return fmt.Errorf("Custodian depots must not be empty")
+		}
+		if slices.Contains(c.Depots[:i], d) {
+			return fmt.Errorf("duplicate depot %q for custodian %q", d, c.ID)
+		}
+	}
+	return nil
+}
+
+// validateCustodianDepots checks that c's depots are not held at another custodian.
+func (s *Store) validateCustodianDepots(c *Custodian) error {
+	for _, d := range c.Depots {
+		if o := s.DepotCustodian(d); o != nil && o.ID != c.ID {
+			return fmt.Errorf("depot %q is already held at custodian %q", d, o.ID)
+		}
+	}
+	return nil
+}
+
+// DepotCustodian returns the custodian at which the given depot is held,
+// or nil if no custodian lists the depot.
+func (s *Store) DepotCustodian(depot string) *Custodian {
+	for _, c := range s.custodians {
+		if slices.Contains(c.Depots, depot) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Custodians returns all custodians, ordered by name.
+func (s *Store) Custodians() []*Custodian {
+	cs := slices.Clone(s.ledger.Custodians)
+	slices.SortFunc(cs, func(a, b *Custodian) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return cs
+}
+
+func (s *Store) FindCustodian(id string) *Custodian {
+	return s.custodians[id]
+}
+
+func (s *Store) AddCustodian(c *Custodian) error {
+	if err := validateCustodian(c); err != nil {
+		return err
+	}
+	if _, ok := s.custodians[c.ID]; ok {
+		return fmt.Errorf("duplicate custodian ID %q", c.ID)
+	}
+	if err := s.validateCustodianDepots(c); err != nil {
+		return err
+	}
+	now := time.Now()
+	c.Created = now
+	c.Modified = now
+	s.custodians[c.ID] = c
+	s.ledger.Custodians = append(s.ledger.Custodians, c)
+	return nil
+}
+
+func (s *Store) UpdateCustodian(id string, c *Custodian) error {
+	old := s.custodians[id]
+	if old == nil {
+		return fmt.Errorf("no custodian with ID %q", id)
+	}
+	if c.ID != old.ID {
+		return fmt.Errorf("custodian ID change is not supported")
+	}
+	if err := validateCustodian(c); err != nil {
+		return err
+	}
+	if err := s.validateCustodianDepots(c); err != nil {
+		return err
+	}
+	created := old.Created
+	*old = *c
+	old.Created = created
+	old.Modified = time.Now()
+	return nil
+}
+
+// DeleteCustodian deletes the custodian with the given ID.
+// Custodians that are still referenced by assets cannot be deleted.
+func (s *Store) DeleteCustodian(id string) error {
+	if _, ok := s.custodians[id]; !ok {
+		return fmt.Errorf("no custodian with ID %q", id)
+	}
+	for _, a := range s.ledger.Assets {
+		if a.Custodian == id {
+			return fmt.Errorf("custodian %q is still referenced by asset %s", id, a.ID())
+		}
+	}
+	delete(s.custodians, id)
+	s.ledger.Custodians = slices.DeleteFunc(s.ledger.Custodians, func(c *Custodian) bool {
+		return c.ID == id
+	})
+	return nil
+}
+
 // AssetPositionItem tracks an individual purchase that is part of the
 // accumulated asset position.
 type AssetPositionItem struct {
@@ -928,6 +1528,15 @@ type AssetPosition struct {
 	// be used to determine profit & loss (P&L) and to update the
 	// accumulated values when an asset is partially sold.
 	Items []AssetPositionItem
+	// Quantities held per depot, keyed by depot name ("" is the default depot).
+	// Nil unless any of the position's entries specified a depot.
+	DepotQuantities map[string]Micros
+}
+
+// DepotQuantity is the quantity of an asset held in a single depot.
+type DepotQuantity struct {
+	Depot          string
+	QuantityMicros Micros
 }
 
 func cmpLedgerEntry(a, b *LedgerEntry) int {
@@ -1118,9 +1727,82 @@ func (p *AssetPosition) Copy() *AssetPosition {
 	q := *p
 	q.Items = make([]AssetPositionItem, len(p.Items))
 	copy(q.Items, p.Items)
+	if p.DepotQuantities != nil {
+		q.DepotQuantities = maps.Clone(p.DepotQuantities)
+	}
 	return &q
 }
 
+// Depots returns the quantities held per depot, ordered by depot name.
+// It returns nil if the position is not split across depots.
+func (p *AssetPosition) Depots() []DepotQuantity {
+	if p.DepotQuantities == nil {
+		return nil
+	}
+	res := make([]DepotQuantity, 0, len(p.DepotQuantities))
+	for d, q := range p.DepotQuantities {
+		res = append(res, DepotQuantity{Depot: d, QuantityMicros: q})
+	}
+	slices.SortFunc(res, func(a, b DepotQuantity) int {
+		return strings.Compare(a.Depot, b.Depot)
+	})
+	return res
+}
+
+// DepotQuantity returns the quantity held in the given depot.
+func (p *AssetPosition) DepotQuantity(depot string) Micros {
+	if p.DepotQuantities == nil {
+		if depot == "" {
+			return p.QuantityMicros
+		}
+		return 0
+	}
+	return p.DepotQuantities[depot]
+}
+
+// addDepotQuantity adds qty to the quantity held in depot.
+// It must be called before qty is added to the position's total quantity.
+func (p *AssetPosition) addDepotQuantity(depot string, qty Micros) {
+	if p.DepotQuantities == nil {
+		if depot == "" {
+			// Not split across depots (yet).
+			return
+		}
+		// Everything held so far is in the default depot.
+		p.DepotQuantities = make(map[string]Micros)
+		if p.QuantityMicros != 0 {
+			p.DepotQuantities[""] = p.QuantityMicros
+		}
+	}
+	p.DepotQuantities[depot] += qty
+	if p.DepotQuantities[depot] == 0 {
+		delete(p.DepotQuantities, depot)
+	}
+}
+
+// removeItems removes qty (a negative quantity) from the position's items,
+// oldest first.
+//
+// Items are not tracked per depot, so a sale from one depot removes the oldest
+// items of any depot. The cost basis of positions split across several depots
+// is therefore only correct in total, not per depot.
+func (p *AssetPosition) removeItems(qty Micros) {
+	for len(p.Items) > 0 {
+		hd := &p.Items[0]
+		if hd.QuantityMicros > -qty {
+			oldQ := hd.QuantityMicros
+			hd.QuantityMicros += qty
+			hd.CostMicros = hd.CostMicros.Frac(hd.QuantityMicros, oldQ)
+			break
+		}
+		qty += hd.QuantityMicros
+		p.Items = p.Items[1:]
+	}
+	if len(p.Items) == 0 {
+		p.Items = nil // allow GC of Items
+	}
+}
+
 func (p *AssetPosition) ID() string {
 	return p.Asset.ID()
 }
@@ -1140,6 +1822,7 @@ func (p *AssetPosition) Update(e *LedgerEntry) {
 	p.LastUpdated = e.ValueDate
 	switch e.Type {
 	case AssetPurchase:
+		p.addDepotQuantity(e.Depot, e.QuantityMicros)
 		p.QuantityMicros += e.QuantityMicros
 		p.SetPrice(e.PriceMicros, e.ValueDate)
 		p.Items = append(p.Items, AssetPositionItem{
@@ -1149,29 +1832,16 @@ func (p *AssetPosition) Update(e *LedgerEntry) {
 			CostMicros:     e.CostMicros,
 		})
 	case AssetSale:
+		p.addDepotQuantity(e.Depot, e.QuantityMicros)
 		p.QuantityMicros += e.QuantityMicros
 		p.SetPrice(e.PriceMicros, e.ValueDate)
-		// Remove items
-		qty := e.QuantityMicros
-		for len(p.Items) > 0 {
-			hd := &p.Items[0]
-			if hd.QuantityMicros > -qty {
-				oldQ := hd.QuantityMicros
-				hd.QuantityMicros += qty
-				hd.CostMicros = hd.CostMicros.Frac(hd.QuantityMicros, oldQ)
-				break
-			}
-			qty += hd.QuantityMicros
-			p.Items = p.Items[1:]
-		}
-		if len(p.Items) == 0 {
-			p.Items = nil // allow GC of Items
-		}
+		p.removeItems(e.QuantityMicros)
 	case AssetMaturity:
 		p.ValueMicros = 0
 		p.QuantityMicros = 0
 		p.SetPrice(e.PriceMicros, e.ValueDate)
 		p.Items = nil
+		p.DepotQuantities = nil
 	case AssetPrice:
 		p.SetPrice(e.PriceMicros, e.ValueDate)
 	case AccountCredit:
@@ -1213,12 +1883,31 @@ func (p *AssetPosition) Update(e *LedgerEntry) {
 		// to keep track of all inpayments/outflows.
 	case AssetHolding:
 		p.SetPrice(e.PriceMicros, e.ValueDate)
-		if e.QuantityMicros != p.QuantityMicros {
+		if e.Depot != "" {
+			// Holding of a single depot: adjust the total position by the
+			// difference to the quantity previously held in that depot.
+			delta := e.QuantityMicros - p.DepotQuantities[e.Depot]
+			p.addDepotQuantity(e.Depot, delta)
+			p.QuantityMicros += delta
+			if delta > 0 {
+				p.Items = append(p.Items, AssetPositionItem{
+					ValueDate:      e.ValueDate,
+					QuantityMicros: delta,
+					PriceMicros:    e.PriceMicros,
+					CostMicros:     e.CostMicros,
+				})
+			} else if delta < 0 {
+				p.removeItems(delta)
+			}
+		} else if e.QuantityMicros != p.QuantityMicros {
 			// Only update position if the quantity has changed,
 			// otherwise consider it an informational ledger entry.
+			// A holding without a depot specifies the total quantity
+			// across all depots, so any per-depot quantities are reset.
 			p.QuantityMicros = e.QuantityMicros
 			p.ValueMicros = e.ValueMicros
 			p.Items = nil
+			p.DepotQuantities = nil
 			if e.QuantityMicros > 0 {
 				p.Items = append(p.Items, AssetPositionItem{
 					ValueDate:      e.ValueDate,
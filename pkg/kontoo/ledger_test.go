@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
@@ -531,6 +532,47 @@ func TestExchangeRatesAt(t *testing.T) {
 	}
 }
 
+func TestConvertAt(t *testing.T) {
+	l := &Ledger{
+		Header: &LedgerHeader{
+			BaseCurrency: "EUR",
+		},
+	}
+	s, _ := NewStore(l, "test")
+	for c, rate := range map[Currency]Micros{"USD": 2 * UnitValue, "CHF": 500_000} {
+		if err := s.Add(&LedgerEntry{
+			Type:          ExchangeRate,
+			Currency:      "EUR",
+			QuoteCurrency: c,
+			PriceMicros:   rate,
+			ValueDate:     DateVal(2024, 1, 1),
+		}); err != nil {
+			t.Fatal("Cannot add exchange rate:", err)
+		}
+	}
+	tests := []struct {
+		from, to Currency
+		date     Date
+		want     Micros
+		wantOK   bool
+	}{
+		{"EUR", "EUR", DateVal(2024, 1, 1), 10 * UnitValue, true},
+		{"USD", "EUR", DateVal(2024, 1, 1), 5 * UnitValue, true},
+		{"EUR", "USD", DateVal(2024, 1, 1), 20 * UnitValue, true},
+		// Cross rate: 10 USD == 5 EUR == 2.50 CHF
+		{"USD", "CHF", DateVal(2024, 1, 1), 2_500_000, true},
+		{"USD", "CHF", DateVal(2023, 12, 31), 0, false},
+		{"USD", "GBP", DateVal(2024, 1, 1), 0, false},
+	}
+	for _, tc := range tests {
+		got, ok := s.ConvertAt(10*UnitValue, tc.from, tc.to, tc.date)
+		if ok != tc.wantOK || got != tc.want {
+			t.Errorf("ConvertAt(%s, %s, %v): want (%v, %v), got (%v, %v)",
+				tc.from, tc.to, tc.date, tc.want, tc.wantOK, got, ok)
+		}
+	}
+}
+
 func TestPositionsAtSavingsAccount(t *testing.T) {
 	l := &Ledger{
 		Assets: []*Asset{
@@ -1125,6 +1167,191 @@ func TestAssetPositionAssetHolding(t *testing.T) {
 	}
 }
 
+func TestAssetPositionDepots(t *testing.T) {
+	asset := &Asset{
+		Type:         Stock,
+		Name:         "Nestle",
+		TickerSymbol: "NESN",
+		Currency:     "CHF",
+	}
+	const u = UnitValue
+	tests := []struct {
+		E      *LedgerEntry
+		Qty    Micros
+		Depots []DepotQuantity
+		Items  int
+	}{
+		{
+			// Purchase without depot: position is not split.
+			E:     &LedgerEntry{Type: AssetPurchase, ValueDate: DateVal(2024, 1, 1), QuantityMicros: 10 * u, PriceMicros: 100 * u},
+			Qty:   10 * u,
+			Items: 1,
+		},
+		{
+			E:   &LedgerEntry{Type: AssetPurchase, ValueDate: DateVal(2024, 1, 2), QuantityMicros: 5 * u, PriceMicros: 100 * u, Depot: "B"},
+			Qty: 15 * u,
+			Depots: []DepotQuantity{
+				{Depot: "", QuantityMicros: 10 * u},
+				{Depot: "B", QuantityMicros: 5 * u},
+			},
+			Items: 2,
+		},
+		{
+			// Holding statement for depot B increases the total position.
+			E:   &LedgerEntry{Type: AssetHolding, ValueDate: DateVal(2024, 2, 1), QuantityMicros: 8 * u, PriceMicros: 110 * u, Depot: "B"},
+			Qty: 18 * u,
+			Depots: []DepotQuantity{
+				{Depot: "", QuantityMicros: 10 * u},
+				{Depot: "B", QuantityMicros: 8 * u},
+			},
+			Items: 3,
+		},
+		{
+			// Selling everything from the default depot removes it.
+			E:   &LedgerEntry{Type: AssetSale, ValueDate: DateVal(2024, 3, 1), QuantityMicros: -10 * u, PriceMicros: 120 * u},
+			Qty: 8 * u,
+			Depots: []DepotQuantity{
+				{Depot: "B", QuantityMicros: 8 * u},
+			},
+			Items: 2,
+		},
+		{
+			// Holding statement for depot B decreases the total position.
+			E:   &LedgerEntry{Type: AssetHolding, ValueDate: DateVal(2024, 4, 1), QuantityMicros: 6 * u, PriceMicros: 120 * u, Depot: "B"},
+			Qty: 6 * u,
+			Depots: []DepotQuantity{
+				{Depot: "B", QuantityMicros: 6 * u},
+			},
+			Items: 2, // Items are removed FIFO, irrespective of their depot.
+		},
+		{
+			// Holding without depot specifies the total quantity.
+			E:     &LedgerEntry{Type: AssetHolding, ValueDate: DateVal(2024, 5, 1), QuantityMicros: 7 * u, PriceMicros: 120 * u},
+			Qty:   7 * u,
+			Items: 1,
+		},
+	}
+	p := &AssetPosition{Asset: asset}
+	for i, tc := range tests {
+		q := p.Copy()
+		p.Update(tc.E)
+		if p.QuantityMicros != tc.Qty {
+			t.Errorf("%d: wrong quantity: want %v, got %v", i, tc.Qty, p.QuantityMicros)
+		}
+		if diff := cmp.Diff(tc.Depots, p.Depots()); diff != "" {
+			t.Errorf("%d: Depots() mismatch (-want, +got): %s", i, diff)
+		}
+		if len(p.Items) != tc.Items {
+			t.Errorf("%d: wrong number of items: want %d, got %d", i, tc.Items, len(p.Items))
+		}
+		if i > 0 {
+			// The copy taken before the update must not share depot quantities.
+			if diff := cmp.Diff(tests[i-1].Depots, q.Depots()); diff != "" {
+				t.Errorf("%d: Copy() was modified by Update (-want, +got): %s", i, diff)
+			}
+		}
+	}
+}
+
+func TestStoreAddDepotInvalidType(t *testing.T) {
+	s, err := newTestStore(nil, Stock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddAsset(&Asset{Name: "Test", Type: Stock, CustomID: "T", Currency: "EUR"}); err != nil {
+		t.Fatal(err)
+	}
+	err = s.Add(&LedgerEntry{
+		Type:        DividendPayment,
+		AssetID:     "T",
+		Currency:    "EUR",
+		ValueDate:   DateVal(2024, 1, 1),
+		ValueMicros: 10 * UnitValue,
+		Depot:       "B",
+	})
+	if err == nil {
+		t.Error("Expected error for Depot on DividendPayment")
+	}
+}
+
+func TestStoreDepotOversell(t *testing.T) {
+	const u = UnitValue
+	s, err := newTestStore([]*LedgerEntry{
+		{Type: AssetPurchase, AssetID: "T", ValueDate: DateVal(2024, 1, 1), QuantityMicros: 10 * u, PriceMicros: 100 * u},
+		{Type: AssetPurchase, AssetID: "T", ValueDate: DateVal(2024, 2, 1), QuantityMicros: 5 * u, PriceMicros: 100 * u, Depot: "A"},
+	}, Stock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without depots, overselling is not checked.
+	plain, err := newTestStore([]*LedgerEntry{
+		{Type: AssetPurchase, AssetID: "P", ValueDate: DateVal(2024, 1, 1), QuantityMicros: 1 * u, PriceMicros: 100 * u},
+	}, Stock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Add(&LedgerEntry{Type: AssetSale, AssetID: "P", ValueDate: DateVal(2024, 2, 1), QuantityMicros: -2 * u, PriceMicros: 100 * u}); err != nil {
+		t.Error("Unexpected error for sale without depots:", err)
+	}
+	sale := func(d Date, q Micros, depot string) *LedgerEntry {
+		return &LedgerEntry{Type: AssetSale, AssetID: "T", ValueDate: d, QuantityMicros: -q, PriceMicros: 100 * u, Depot: depot}
+	}
+	tests := []struct {
+		name    string
+		e       *LedgerEntry
+		wantErr bool
+	}{
+		{"default depot", sale(DateVal(2024, 3, 1), 10*u, ""), false},
+		{"default depot oversold", sale(DateVal(2024, 3, 1), 11*u, ""), true},
+		{"depot A", sale(DateVal(2024, 3, 1), 5*u, "A"), false},
+		{"depot A oversold", sale(DateVal(2024, 3, 1), 6*u, "A"), true},
+		{"depot A before purchase", sale(DateVal(2024, 1, 15), 1*u, "A"), true},
+		{"unknown depot", sale(DateVal(2024, 3, 1), 1*u, "B"), true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := *s.ledger
+			c.Entries = slices.Clone(s.ledger.Entries)
+			s, err := NewStore(&c, "/test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.Add(tc.e)
+			if tc.wantErr && err == nil {
+				t.Error("Expected error, got nil")
+			} else if !tc.wantErr && err != nil {
+				t.Error("Unexpected error:", err)
+			}
+		})
+	}
+	// Reducing the purchase into depot A would oversell the later sale from A.
+	if err := s.Add(sale(DateVal(2024, 3, 1), 5*u, "A")); err != nil {
+		t.Fatal(err)
+	}
+	upd := *s.ledger.Entries[1]
+	upd.QuantityMicros = 4 * u
+	if err := s.Update(&upd); err == nil {
+		t.Error("Expected error when update oversells a later sale")
+	}
+	if got := s.ledger.Entries[1].QuantityMicros; got != 5*u {
+		t.Errorf("Entry was modified despite error: quantity %v", got)
+	}
+	// Restoring a sale must not oversell the depot either.
+	saleSeq := s.ledger.Entries[2].SequenceNum
+	if err := s.Delete(saleSeq); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(sale(DateVal(2024, 4, 1), 5*u, "A")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Restore(saleSeq); err == nil {
+		t.Error("Expected error when restored sale oversells the depot")
+	}
+	if len(s.Trash()) != 1 {
+		t.Error("Entry should remain in the trash")
+	}
+}
+
 // newTestStore is a test helper to create a store from a list of ledger entries.
 // All assets will use CustomID as the ID field and have asset type t.
 // The ledger's base currency is EUR.
@@ -1301,6 +1528,75 @@ func TestSaveLoadStore(t *testing.T) {
 	}
 }
 
+func TestCustodians(t *testing.T) {
+	s, err := NewStore(&Ledger{}, filepath.Join(t.TempDir(), "ledger.jsonl"))
+	if err != nil {
+		t.Fatal("Could not create store:", err)
+	}
+	if err := s.AddCustodian(&Custodian{ID: "cd", Name: "Comdirect"}); err != nil {
+		t.Fatal("AddCustodian failed:", err)
+	}
+	if err := s.AddCustodian(&Custodian{ID: "cd", Name: "Other"}); err == nil {
+		t.Error("Expected error for duplicate custodian ID")
+	}
+	if err := s.AddCustodian(&Custodian{ID: "x"}); err == nil {
+		t.Error("Expected error for custodian without name")
+	}
+	if err := s.AddAsset(&Asset{Type: Stock, Name: "Foo", TickerSymbol: "FOO", Currency: "EUR", Custodian: "nope"}); err == nil {
+		t.Error("Expected error for unknown custodian")
+	}
+	if err := s.AddAsset(&Asset{Type: Stock, Name: "Foo", TickerSymbol: "FOO", Currency: "EUR", Custodian: "cd"}); err != nil {
+		t.Fatal("AddAsset failed:", err)
+	}
+	if err := s.UpdateCustodian("cd", &Custodian{ID: "cd", Name: "Comdirect Bank", CustodyFeeMicros: 2500}); err != nil {
+		t.Fatal("UpdateCustodian failed:", err)
+	}
+	if err := s.UpdateCustodian("cd", &Custodian{ID: "cd2", Name: "Comdirect"}); err == nil {
+		t.Error("Expected error for custodian ID change")
+	}
+	if got := s.FindCustodian("cd"); got == nil || got.Name != "Comdirect Bank" || got.Created.IsZero() {
+		t.Errorf("Custodian was not updated properly: %v", got)
+	}
+	if err := s.DeleteCustodian("cd"); err == nil {
+		t.Error("Expected error when deleting custodian referenced by an asset")
+	}
+	if err := s.UpdateCustodian("cd", &Custodian{ID: "cd", Name: "Comdirect Bank", CustodyFeeMicros: 2500, Depots: []string{"cd1", "cd2"}}); err != nil {
+		t.Fatal("UpdateCustodian with depots failed:", err)
+	}
+	if c := s.DepotCustodian("cd2"); c == nil || c.ID != "cd" {
+		t.Errorf("Wrong custodian for depot cd2: %v", c)
+	}
+	if err := s.AddCustodian(&Custodian{ID: "dkb", Name: "DKB", Depots: []string{"cd2"}}); err == nil {
+		t.Error("Expected error for depot held at two custodians")
+	}
+	if err := s.AddCustodian(&Custodian{ID: "dkb", Name: "DKB", Depots: []string{"d", "d"}}); err == nil {
+		t.Error("Expected error for duplicate depot")
+	}
+	if err := s.AddCustodian(&Custodian{ID: "dkb", Name: "DKB", Depots: []string{" "}}); err == nil {
+		t.Error("Expected error for empty depot")
+	}
+	// Custodians must survive a save and reload.
+	if err := s.Save(); err != nil {
+		t.Fatal("Could not save store:", err)
+	}
+	s2, err := LoadStore(s.path)
+	if err != nil {
+		t.Fatal("Could not load store:", err)
+	}
+	if diff := cmp.Diff(s.ledger, s2.ledger); diff != "" {
+		t.Errorf("Loaded ledger differs (-want +got):\n%s", diff)
+	}
+	if err := s.AddCustodian(&Custodian{ID: "ing", Name: "ING"}); err != nil {
+		t.Fatal("AddCustodian failed:", err)
+	}
+	if err := s.DeleteCustodian("ing"); err != nil {
+		t.Fatal("DeleteCustodian failed:", err)
+	}
+	if got := len(s.Custodians()); got != 1 {
+		t.Errorf("Wrong number of custodians: want 1, got %d", got)
+	}
+}
+
 func TestSaveLoadEmpty(t *testing.T) {
 	l := Ledger{}
 	path := filepath.Join(t.TempDir(), "ledger.json")
@@ -1402,3 +1698,363 @@ func TestMarshalDate(t *testing.T) {
 		}
 	}
 }
+
+func TestStoreTrash(t *testing.T) {
+	entries := []*LedgerEntry{
+		{Type: AccountCredit, AssetID: ibanDE100, ValueDate: DateVal(2023, 1, 1), ValueMicros: 100 * UnitValue},
+		{Type: AccountCredit, AssetID: ibanDE100, ValueDate: DateVal(2023, 1, 2), ValueMicros: 20 * UnitValue},
+		{Type: AccountCredit, AssetID: ibanDE100, ValueDate: DateVal(2023, 1, 3), ValueMicros: 3 * UnitValue},
+	}
+	s, err := newTestStore(entries, CheckingAccount)
+	if err != nil {
+		t.Fatalf("Failed to create Store: %v", err)
+	}
+	value := func() Micros {
+		return s.AssetPositionAt(ibanDE100, DateVal(2023, 12, 31)).MarketValue()
+	}
+	for _, seq := range []int64{3, 2} {
+		if err := s.Delete(seq); err != nil {
+			t.Fatalf("Delete(%d) failed: %v", seq, err)
+		}
+	}
+	if len(s.Trash()) != 2 {
+		t.Fatalf("Wrong number of trashed entries: %d", len(s.Trash()))
+	}
+	// New entries must not reuse the sequence numbers of trashed ones.
+	if seq := s.nextSequenceNum(); seq != 4 {
+		t.Errorf("Wrong next sequence number: want 4, got %d", seq)
+	}
+	if err := s.Restore(2); err != nil {
+		t.Fatal("Restore failed:", err)
+	}
+	if err := s.Restore(2); err == nil {
+		t.Error("Expected error when restoring entry twice")
+	}
+	if got := value(); got != 120*UnitValue {
+		t.Errorf("Wrong value after restore: %v", got)
+	}
+	var seqs []int64
+	for _, e := range s.ledger.Entries {
+		seqs = append(seqs, e.SequenceNum)
+	}
+	if diff := cmp.Diff([]int64{1, 2}, seqs); diff != "" {
+		t.Errorf("Wrong ledger sequence numbers (-want +got): %s", diff)
+	}
+	if err := s.Purge(3); err != nil {
+		t.Fatal("Purge failed:", err)
+	}
+	if err := s.Restore(3); err == nil {
+		t.Error("Expected error when restoring purged entry")
+	}
+	// Retention-based purging.
+	if err := s.Delete(1); err != nil {
+		t.Fatal("Delete failed:", err)
+	}
+	s.ledger.Trash[0].Deleted = time.Now().Add(-48 * time.Hour)
+	if n := s.PurgeTrash(time.Now().Add(-24 * time.Hour)); n != 1 {
+		t.Errorf("Wrong number of purged entries: %d", n)
+	}
+	if len(s.Trash()) != 0 {
+		t.Errorf("Trash should be empty: %v", s.Trash())
+	}
+}
+
+func TestStoreBookmarks(t *testing.T) {
+	entries := []*LedgerEntry{
+		{Type: AccountCredit, AssetID: ibanDE100, ValueDate: DateVal(2023, 1, 1), ValueMicros: 100 * UnitValue, Pinned: true},
+		{Type: AccountCredit, AssetID: ibanDE100, ValueDate: DateVal(2023, 1, 2), ValueMicros: 20 * UnitValue},
+		{Type: AccountCredit, AssetID: ibanDE100, ValueDate: DateVal(2023, 1, 3), ValueMicros: 3 * UnitValue},
+	}
+	s, err := newTestStore(entries, CheckingAccount)
+	if err != nil {
+		t.Fatalf("Failed to create Store: %v", err)
+	}
+	if err := s.SetPinned(3, true); err != nil {
+		t.Fatal("SetPinned failed:", err)
+	}
+	if err := s.SetPinned(100, true); err == nil {
+		t.Error("Expected error for unknown sequence number")
+	}
+	var seqs []int64
+	for _, b := range s.Bookmarks() {
+		seqs = append(seqs, b.SequenceNum())
+	}
+	// Most recent first.
+	if diff := cmp.Diff([]int64{3, 1}, seqs); diff != "" {
+		t.Errorf("Wrong bookmarks (-want +got): %s", diff)
+	}
+	if err := s.SetPinned(1, false); err != nil {
+		t.Fatal("SetPinned failed:", err)
+	}
+	if n := len(s.Bookmarks()); n != 1 {
+		t.Errorf("Wrong number of bookmarks after unpinning: %d", n)
+	}
+}
+
+func TestLedgerSettings(t *testing.T) {
+	s, err := NewStore(NewLedger("EUR"), "/test")
+	if err != nil {
+		t.Fatal("Could not create store:", err)
+	}
+	if !s.FeatureEnabled(FeatureRisk) {
+		t.Error("Risk should be enabled by default")
+	}
+	if s.FeatureEnabled("nonexistent") {
+		t.Error("Unknown features should be disabled")
+	}
+	l := NewLedger("EUR")
+	l.Header.Settings = &LedgerSettings{
+		Features: map[string]bool{FeatureRisk: false},
+	}
+	s, err = NewStore(l, "/test")
+	if err != nil {
+		t.Fatal("Could not create store:", err)
+	}
+	if s.FeatureEnabled(FeatureRisk) {
+		t.Error("Risk should be disabled")
+	}
+	if !s.FeatureEnabled(FeatureSearch) {
+		t.Error("Search should be enabled by default")
+	}
+	invalid := []*LedgerSettings{
+		{Features: map[string]bool{"warp-drive": true}},
+		{CustomLinks: []*NavLink{{Title: "", URL: "https://example.com"}}},
+		{CustomLinks: []*NavLink{{Title: "Bank"}}},
+	}
+	for _, st := range invalid {
+		l := NewLedger("EUR")
+		l.Header.Settings = st
+		if _, err := NewStore(l, "/test"); err == nil {
+			t.Errorf("Expected error for settings %+v", st)
+		}
+	}
+}
+
+func TestReportingCategory(t *testing.T) {
+	l := &Ledger{
+		Header: &LedgerHeader{
+			BaseCurrency: "EUR",
+			Settings:     &LedgerSettings{CashEquivalentMonths: 12},
+		},
+		Assets: []*Asset{
+			{
+				Name:         "Short bond",
+				Type:         GovernmentBond,
+				ISIN:         "DE01",
+				MaturityDate: newDate(2024, 6, 30),
+				Currency:     "EUR",
+			},
+			{
+				Name:         "Long bond",
+				Type:         GovernmentBond,
+				ISIN:         "DE02",
+				MaturityDate: newDate(2030, 6, 30),
+				Currency:     "EUR",
+			},
+			{
+				Name:         "Stock",
+				Type:         Stock,
+				TickerSymbol: "STCK",
+				Currency:     "EUR",
+			},
+		},
+	}
+	s, err := NewStore(l, "/test")
+	if err != nil {
+		t.Fatal("Could not create store", err)
+	}
+	tests := []struct {
+		assetID string
+		date    Date
+		want    AssetCategory
+	}{
+		{"DE01", DateVal(2024, 1, 1), CashEquivalents},
+		{"DE01", DateVal(2023, 1, 1), FixedIncome},
+		{"DE02", DateVal(2024, 1, 1), FixedIncome},
+		{"DE02", DateVal(2029, 12, 31), CashEquivalents},
+		{"STCK", DateVal(2024, 1, 1), Equity},
+	}
+	for _, tc := range tests {
+		if got := s.ReportingCategory(s.assets[tc.assetID], tc.date); got != tc.want {
+			t.Errorf("ReportingCategory(%s, %s): want %v, got %v", tc.assetID, tc.date, tc.want, got)
+		}
+	}
+	// Reclassification is disabled by default.
+	l.Header.Settings = nil
+	if got := s.ReportingCategory(s.assets["DE01"], DateVal(2024, 1, 1)); got != FixedIncome {
+		t.Errorf("Want FixedIncome without settings, got %v", got)
+	}
+}
+
+func TestShiftDatesUndo(t *testing.T) {
+	l := &Ledger{
+		Assets: []*Asset{
+			{
+				Name:     "Test",
+				Type:     SavingsAccount,
+				IBAN:     ibanDE100,
+				Currency: "EUR",
+			},
+		},
+	}
+	s, err := NewStore(l, "/test")
+	if err != nil {
+		t.Fatal("Could not create store", err)
+	}
+	for _, d := range []Date{DateVal(2024, 1, 10), DateVal(2024, 1, 20), DateVal(2024, 1, 30)} {
+		err := s.Add(&LedgerEntry{
+			Type:        AccountBalance,
+			AssetID:     ibanDE100,
+			ValueDate:   d,
+			ValueMicros: Micros(d.Day()) * UnitValue,
+		})
+		if err != nil {
+			t.Fatal("Cannot add to ledger:", err)
+		}
+	}
+	balanceAt := func(d Date) Micros {
+		return s.AssetPositionAt(ibanDE100, d).ValueMicros
+	}
+	// Move the first entry after the second one.
+	if _, err := s.ShiftDates([]int64{1}, 15); err != nil {
+		t.Fatal("ShiftDates failed:", err)
+	}
+	if got := s.FindEntryBySequenceNum(1).ValueDate; got != DateVal(2024, 1, 25) {
+		t.Errorf("Wrong value date after shift: %v", got)
+	}
+	if got := balanceAt(DateVal(2024, 1, 26)); got != 10*UnitValue {
+		t.Errorf("Wrong balance after shift: %v", got)
+	}
+	if s.LastDateShift() == nil {
+		t.Fatal("Expected last date shift")
+	}
+	if _, err := s.UndoDateShift(); err != nil {
+		t.Fatal("UndoDateShift failed:", err)
+	}
+	if got := balanceAt(DateVal(2024, 1, 26)); got != 20*UnitValue {
+		t.Errorf("Wrong balance after undo: %v", got)
+	}
+	if _, err := s.UndoDateShift(); err == nil {
+		t.Error("Expected error for second undo")
+	}
+	// Undo fails if an entry was changed after the shift.
+	if _, err := s.ShiftDates([]int64{2, 3}, -1); err != nil {
+		t.Fatal("ShiftDates failed:", err)
+	}
+	if err := s.Delete(3); err != nil {
+		t.Fatal("Delete failed:", err)
+	}
+	if _, err := s.UndoDateShift(); err == nil {
+		t.Error("Expected error for undo of deleted entry")
+	}
+	if got := s.FindEntryBySequenceNum(2).ValueDate; got != DateVal(2024, 1, 19) {
+		t.Errorf("Failed undo should not modify entries, got %v", got)
+	}
+	if _, err := s.ShiftDates([]int64{1000}, 1); err == nil {
+		t.Error("Expected error for unknown entry")
+	}
+	// The last shift must survive a save and reload.
+	s.path = filepath.Join(t.TempDir(), "ledger.jsonl")
+	if err := s.Save(); err != nil {
+		t.Fatal("Could not save store:", err)
+	}
+	s2, err := LoadStore(s.path)
+	if err != nil {
+		t.Fatal("Could not load store:", err)
+	}
+	if diff := cmp.Diff(s.LastDateShift(), s2.LastDateShift()); diff != "" {
+		t.Errorf("Loaded date shift differs (-want +got):\n%s", diff)
+	}
+	// Exchange rates are indexed separately, depending on their base currency.
+	s, err = NewStore(&Ledger{Header: &LedgerHeader{BaseCurrency: "EUR"}}, "/test")
+	if err != nil {
+		t.Fatal("Could not create store", err)
+	}
+	for _, c := range []Currency{"EUR", "USD"} {
+		err := s.Add(&LedgerEntry{
+			Type:          ExchangeRate,
+			ValueDate:     DateVal(2024, 1, 10),
+			Currency:      c,
+			QuoteCurrency: "CHF",
+			PriceMicros:   UnitValue,
+		})
+		if err != nil {
+			t.Fatal("Cannot add to ledger:", err)
+		}
+	}
+	checkIndexes := func(want Date) {
+		t.Helper()
+		if es := s.exchangeRates["CHF"]; len(es) != 1 || es[0].ValueDate != want {
+			t.Errorf("Wrong exchange rate index: %v", es)
+		}
+		if es := s.entries[""]; len(es) != 1 || es[0].ValueDate != want {
+			t.Errorf("Wrong index of non-base exchange rates: %v", es)
+		}
+	}
+	if _, err := s.ShiftDates([]int64{1, 2}, 5); err != nil {
+		t.Fatal("ShiftDates failed:", err)
+	}
+	checkIndexes(DateVal(2024, 1, 15))
+	if _, err := s.UndoDateShift(); err != nil {
+		t.Fatal("UndoDateShift failed:", err)
+	}
+	checkIndexes(DateVal(2024, 1, 10))
+}
+
+func TestShiftDatesValidatesPositions(t *testing.T) {
+	const u = UnitValue
+	s, err := newTestStore([]*LedgerEntry{
+		{Type: AssetPurchase, AssetID: "T", ValueDate: DateVal(2024, 1, 1), QuantityMicros: 10 * u, PriceMicros: 100 * u},
+		{Type: AssetPurchase, AssetID: "T", ValueDate: DateVal(2024, 2, 1), QuantityMicros: 5 * u, PriceMicros: 100 * u, Depot: "A"},
+		{Type: AssetSale, AssetID: "T", ValueDate: DateVal(2024, 3, 1), QuantityMicros: -3 * u, PriceMicros: 100 * u, Depot: "A"},
+		{Type: AssetSale, AssetID: "T", ValueDate: DateVal(2024, 3, 1), QuantityMicros: -8 * u, PriceMicros: 100 * u},
+	}, Stock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		seqNums []int64
+		days    int
+		wantErr bool
+	}{
+		{"sale before purchase", []int64{4}, -70, true},
+		{"sale before depot purchase", []int64{3}, -30, true},
+		{"purchase after sale", []int64{1}, 90, true},
+		{"sale later", []int64{3, 4}, 10, false},
+		{"all entries", []int64{1, 2, 3, 4}, -100, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dates := make(map[int64]Date)
+			for _, e := range s.ledger.Entries {
+				dates[e.SequenceNum] = e.ValueDate
+			}
+			shift, err := s.PlanDateShift(tc.seqNums, tc.days)
+			if err != nil {
+				t.Fatal("PlanDateShift failed:", err)
+			}
+			if err := s.ValidateDateShift(shift); (err != nil) != tc.wantErr {
+				t.Errorf("ValidateDateShift: got error %v, want error: %t", err, tc.wantErr)
+			}
+			_, err = s.ShiftDates(tc.seqNums, tc.days)
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatal("ShiftDates failed:", err)
+				}
+				if _, err := s.UndoDateShift(); err != nil {
+					t.Fatal("UndoDateShift failed:", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			for _, e := range s.ledger.Entries {
+				if e.ValueDate != dates[e.SequenceNum] {
+					t.Errorf("Failed shift modified entry #%d: %v", e.SequenceNum, e.ValueDate)
+				}
+			}
+		})
+	}
+}
@@ -73,6 +73,14 @@ func (a Micros) Frac(numer, denom Micros) Micros {
 	return Micros(bigA.Int64())
 }
 
+// Abs returns the absolute value of m.
+func (m Micros) Abs() Micros {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
 // SplitFrac splits m into its integer and fractional parts.
 // If m is negative, both parts will have a negative sign,
 // unless one of them is zero.
@@ -17,6 +17,21 @@ type fieldTerm struct {
 	re      *regexp.Regexp
 }
 
+// amountTerm matches entries whose value or price is within
+// tolerance of amount. Signs are ignored.
+type amountTerm struct {
+	negated   bool
+	amount    Micros
+	tolerance Micros
+}
+
+func (t *amountTerm) match(m Micros) bool {
+	if m < 0 {
+		m = -m
+	}
+	return m >= t.amount-t.tolerance && m <= t.amount+t.tolerance
+}
+
 type fieldOrdering struct {
 	name       string
 	descending bool
@@ -26,6 +41,8 @@ type Query struct {
 	raw          string
 	terms        []string
 	fieldTerms   []fieldTerm
+	amountTerms  []amountTerm
+	pinned       *bool   // If set, only match entries whose Pinned flag has the given value.
 	sequenceNums []int64 // 2-pairs of inclusive ranges of valid sequence numbers. empty means "all numbers".
 	fromDate     Date
 	untilDate    Date
@@ -228,6 +245,22 @@ func ParseQuery(rawQuery string) (*Query, error) {
 					q.sequenceNums = append(q.sequenceNums, n, n)
 				}
 			}
+		} else if f == "is" {
+			if ft[sep] != ':' || t != "pinned" {
+				return nil, fmt.Errorf("only is:pinned is supported, got %q", ft)
+			}
+			pinned := !neg
+			q.pinned = &pinned
+		} else if f == "amount" {
+			if ft[sep] != ':' {
+				return nil, fmt.Errorf("only operator : is allowed for %q filter", f)
+			}
+			a, err := parseAmountTerm(t)
+			if err != nil {
+				return nil, err
+			}
+			a.negated = neg
+			q.amountTerms = append(q.amountTerms, a)
 		} else if f == "date" || f == "year" || f == "from" || f == "until" {
 			// Dates
 			if ft[sep] != ':' {
@@ -294,19 +327,30 @@ func matchLower(s, t string) bool {
 }
 
 func matchAsset(t string, a *Asset) bool {
-	if matchLower(a.AccountNumber, t) ||
-		matchLower(a.IBAN, t) || matchLower(a.ISIN, t) ||
-		matchLower(a.Name, t) || matchLower(a.ShortName, t) ||
-		matchLower(a.TickerSymbol, t) || matchLower(a.WKN, t) ||
-		matchLower(a.CustomID, t) || matchLower(a.Comment, t) {
-		return true
+	return scoreAsset(t, a) > 0
+}
+
+// parseAmountTerm parses an amount with an optional tolerance, e.g. "123.45~0.05".
+// The tolerance can also be given relative to the amount, e.g. "1000~1%".
+func parseAmountTerm(t string) (amountTerm, error) {
+	var a amountTerm
+	amount, tol, hasTol := strings.Cut(t, "~")
+	if err := ParseDecimalAsMicros(amount, &a.amount); err != nil {
+		return a, fmt.Errorf("invalid amount: %q", amount)
 	}
-	for _, s := range a.QuoteServiceSymbols {
-		if matchLower(s, t) {
-			return true
-		}
+	if a.amount < 0 {
+		a.amount = -a.amount
+	}
+	if !hasTol {
+		return a, nil
+	}
+	if err := ParseDecimalAsMicros(tol, &a.tolerance); err != nil || a.tolerance < 0 {
+		return a, fmt.Errorf("invalid tolerance for amount: %q", tol)
+	}
+	if strings.HasSuffix(tol, "%") {
+		a.tolerance = a.amount.Mul(a.tolerance)
 	}
-	return false
+	return a, nil
 }
 
 func (q *Query) Match(e *LedgerEntryRow) bool {
@@ -339,6 +383,8 @@ func (q *Query) Match(e *LedgerEntryRow) bool {
 			fval = e.EntryType().String()
 		case "class":
 			fval = e.AssetType().DisplayName()
+		case "depot":
+			fval = e.E.Depot
 		}
 		if fval == "" {
 			//  Match fails for unsupported (and empty) fields
@@ -354,6 +400,17 @@ func (q *Query) Match(e *LedgerEntryRow) bool {
 			}
 		}
 	}
+	// Amounts
+	for i := range q.amountTerms {
+		t := &q.amountTerms[i]
+		m := t.match(e.E.ValueMicros) || t.match(e.E.PriceMicros)
+		if m == t.negated {
+			return false
+		}
+	}
+	if q.pinned != nil && e.E.Pinned != *q.pinned {
+		return false
+	}
 	// Time range
 	if !q.fromDate.IsZero() && q.fromDate.After(e.ValueDate().Time) {
 		return false
@@ -147,6 +147,33 @@ func TestParseQueryDates(t *testing.T) {
 	}
 }
 
+func TestParseQueryAmount(t *testing.T) {
+	tests := []struct {
+		q    string
+		want amountTerm
+	}{
+		{"amount:123.45", amountTerm{amount: 123_450_000}},
+		{"amount:-123.45", amountTerm{amount: 123_450_000}},
+		{"amount:123.45~0.05", amountTerm{amount: 123_450_000, tolerance: 50_000}},
+		{"amount:1000~1%", amountTerm{amount: 1000 * UnitValue, tolerance: 10 * UnitValue}},
+		{"!amount:10", amountTerm{amount: 10 * UnitValue, negated: true}},
+	}
+	for _, tc := range tests {
+		q, err := ParseQuery(tc.q)
+		if err != nil {
+			t.Fatalf("Cannot parse query %q: %v", tc.q, err)
+		}
+		if diff := cmp.Diff([]amountTerm{tc.want}, q.amountTerms, cmp.AllowUnexported(amountTerm{})); diff != "" {
+			t.Errorf("wrong amount terms for %q (-want +got): %s", tc.q, diff)
+		}
+	}
+	for _, q := range []string{"amount:abc", "amount:10~x", "amount:10~-1", "amount~10"} {
+		if _, err := ParseQuery(q); err == nil {
+			t.Errorf("Expected error for query %q", q)
+		}
+	}
+}
+
 func TestParseQuerySequenceNum(t *testing.T) {
 	tests := []struct {
 		q       string
@@ -206,6 +233,22 @@ func TestQueryMatch(t *testing.T) {
 			Type: Stock,
 		},
 	}
+	rAmount := &LedgerEntryRow{
+		E: &LedgerEntry{
+			ValueMicros: -123_450_000,
+			PriceMicros: 7 * UnitValue,
+		},
+	}
+	rDepot := &LedgerEntryRow{
+		E: &LedgerEntry{
+			Depot: "Comdirect",
+		},
+	}
+	rPinned := &LedgerEntryRow{
+		E: &LedgerEntry{
+			Pinned: true,
+		},
+	}
 	tests := []struct {
 		q    string
 		e    *LedgerEntryRow
@@ -231,6 +274,24 @@ func TestQueryMatch(t *testing.T) {
 		{q: "class:stock", e: rType, want: true},
 		{q: "class:bond", e: rType, want: false},
 		{q: "class:equi", e: rType, want: false},
+
+		{q: "depot:comd", e: rDepot, want: true},
+		{q: "depot:flatex", e: rDepot, want: false},
+		{q: "depot:comd", e: rType, want: false},
+		{q: "!depot:flatex", e: rDepot, want: true},
+
+		{q: "amount:123.45", e: rAmount, want: true},
+		{q: "amount:123.40~0.05", e: rAmount, want: true},
+		{q: "amount:123.40~0.04", e: rAmount, want: false},
+		{q: "amount:7", e: rAmount, want: true},
+		{q: "amount:120~3%", e: rAmount, want: true},
+		{q: "!amount:7", e: rAmount, want: false},
+		{q: "amount:7 amount:123.45", e: rAmount, want: true},
+
+		{q: "is:pinned", e: rPinned, want: true},
+		{q: "is:pinned", e: r100, want: false},
+		{q: "!is:pinned", e: r100, want: true},
+		{q: "!is:pinned", e: rPinned, want: false},
 	}
 	for _, tc := range tests {
 		q, err := ParseQuery(tc.q)
@@ -10,7 +10,6 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"path"
 	"slices"
 	"strconv"
 	"strings"
@@ -34,9 +33,22 @@ type DailyQuote struct {
 	ClosingPrice Micros
 }
 
+// QuoteProvider provides daily stock quotes and exchange rates.
+// YFinance is the only real implementation. FakeQuoteProvider can be used
+// in tests and for running the server offline.
+type QuoteProvider interface {
+	// GetDailyQuote returns the closing price of symbol on the given date.
+	GetDailyQuote(symbol string, date time.Time) (*DailyQuote, error)
+	// GetDailyExchangeRate returns the closing exchange rate on the given date.
+	GetDailyExchangeRate(baseCurrency Currency, quoteCurrency Currency, date time.Time) (*DailyExchangeRate, error)
+	// FetchQuoteSummary returns summary data (e.g., the exchange's time zone) for symbol.
+	FetchQuoteSummary(symbol string) (*YFQuoteSummaryResponse, error)
+}
+
 type YFinance struct {
 	client         *http.Client
 	cookieJar      CookieJar
+	secrets        SecretStore // Persists the cookie jar.
 	cache          *PriceHistoryCache
 	tracingEnabled bool // Log Y! requests/responses to stdout
 }
@@ -124,15 +136,26 @@ func (j *CookieJar) AddCookies(req *http.Request) {
 	}
 }
 
+// NewYFinance returns a YFinance client that stores its cookie jar
+// in the SecretStore selected by the environment (see NewSecretStoreFromEnv).
 func NewYFinance() (*YFinance, error) {
+	secrets, err := NewSecretStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewYFinanceWithSecrets(secrets)
+}
+
+func NewYFinanceWithSecrets(secrets SecretStore) (*YFinance, error) {
 	yf := &YFinance{
 		client: &http.Client{
 			Timeout: 5 * time.Second,
 		},
-		cache: NewPriceHistoryCache(),
+		secrets: secrets,
+		cache:   NewPriceHistoryCache(),
 	}
 	if err := yf.LoadCookieJar(); err != nil {
-		if os.IsNotExist(err) || errors.Is(err, ErrCookiesExpired) {
+		if errors.Is(err, ErrSecretNotFound) || errors.Is(err, ErrCookiesExpired) {
 			if err := yf.RefreshCookieJar(); err != nil {
 				return nil, err
 			}
@@ -147,19 +170,8 @@ var (
 	ErrNotCached      = errors.New("requested entry not found in cache")
 )
 
-func (yf *YFinance) cookieJarFile() string {
-	if f := os.Getenv(cookieJarEnvVar); f != "" {
-		return f
-	}
-	filename := ".yfcookiejar"
-	if home, err := os.UserHomeDir(); err == nil {
-		return path.Join(home, filename)
-	}
-	return path.Join(".", filename)
-}
-
 func (yf *YFinance) LoadCookieJar() error {
-	data, err := os.ReadFile(yf.cookieJarFile())
+	data, err := yf.secrets.Get(yfCookieJarKey)
 	if err != nil {
 		return err
 	}
@@ -203,14 +215,14 @@ func (yf *YFinance) RefreshCookieJar() error {
 		return err
 	}
 	cookieJar.Crumb = crumb
-	// Try to save the jar to disk.
+	yf.cookieJar.Crumb = crumb
+	// Try to save the jar.
 	data, err := json.Marshal(cookieJar)
 	if err != nil {
 		log.Fatalf("Cannot marshal JSON: %v", err)
 	}
-	jarFile := yf.cookieJarFile()
-	if err := os.WriteFile(jarFile, data, 0644); err != nil {
-		log.Printf("Cannot write cookie jar to %q: %v", jarFile, err)
+	if err := yf.secrets.Put(yfCookieJarKey, data); err != nil {
+		log.Printf("Cannot store cookie jar: %v", err)
 		// Don't treat this as an error: if we're on a diskless machine,
 		// we'll just use the in-memory crumb.
 	}
@@ -572,24 +584,36 @@ func ReadDepotExportCSVFile(path string) ([]*DepotExportItem, error) {
 	return ReadDepotExportCSV(encIn)
 }
 
+// DepotExportFields are the fields of a DepotExportItem that are read from a CSV file.
+var DepotExportFields = []string{"WKN", "Quantity", "Currency", "Price", "Value", "ValueDate"}
+
+// DefaultDepotExportColumns maps the CSV headers of the default depot export
+// format to the DepotExportFields they are read into.
+var DefaultDepotExportColumns = map[string]string{
+	"Stück/Nom.":  "Quantity",
+	"WKN":         "WKN",
+	"Währung":     "Currency",
+	"Akt. Kurs":   "Price",
+	"Wert in EUR": "Value",
+	"Datum":       "ValueDate",
+}
+
 // ReadDepotExportCSV is designed to read CSV exports of account positions
 // provided by a specific German bank.
 // It expects a set of headers to be present (in any order) and ignores
 // all other headers. It also expects German formats for decimal
 // numbers and dates as well as the use of ; as the column separator.
 func ReadDepotExportCSV(reader io.Reader) ([]*DepotExportItem, error) {
+	return ReadDepotExportCSVColumns(reader, DefaultDepotExportColumns)
+}
+
+// ReadDepotExportCSVColumns is like ReadDepotExportCSV, but reads the
+// DepotExportFields from the columns with the headers given in columns.
+func ReadDepotExportCSVColumns(reader io.Reader, columns map[string]string) ([]*DepotExportItem, error) {
 	r := csv.NewReader(reader)
 	r.Comma = ';'
 	firstRow := true
 	colIdx := make(map[string]int)
-	knownHdr := map[string]string{
-		"Stück/Nom.":  "Quantity",
-		"WKN":         "WKN",
-		"Währung":     "Currency",
-		"Akt. Kurs":   "Price",
-		"Wert in EUR": "Value",
-		"Datum":       "ValueDate",
-	}
 	var result []*DepotExportItem
 	for {
 		row, err := r.Read()
@@ -601,17 +625,17 @@ func ReadDepotExportCSV(reader io.Reader) ([]*DepotExportItem, error) {
 		}
 		if firstRow {
 			for i, h := range row {
-				if s, ok := knownHdr[h]; ok {
-					colIdx[s] = i
+				if f, ok := columns[h]; ok {
+					colIdx[f] = i
 				}
 			}
-			if len(colIdx) != len(knownHdr) {
-				missing := make([]string, 0, len(knownHdr))
-				for k := range knownHdr {
-					if _, ok := colIdx[k]; !ok {
-						missing = append(missing, k)
-					}
+			var missing []string
+			for _, f := range DepotExportFields {
+				if _, ok := colIdx[f]; !ok {
+					missing = append(missing, f)
 				}
+			}
+			if len(missing) > 0 {
 				return nil, fmt.Errorf("not all expected headers present: missing: %v",
 					strings.Join(missing, ";"))
 			}
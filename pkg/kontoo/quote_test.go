@@ -2,10 +2,14 @@ package kontoo
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
+	"io"
+	"net/http"
 	"os"
 	"path"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -106,6 +110,112 @@ func TestRequestChartNotFound(t *testing.T) {
 	}
 }
 
+// fixtureTransport serves recorded Y! Finance responses from testdata.
+// Requests for other URL paths are answered like requests for unknown symbols.
+type fixtureTransport struct {
+	files map[string]string // URL path => testdata file
+}
+
+func (f *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	file, ok := f.files[req.URL.Path]
+	if !ok {
+		file = "chart_error.json"
+	}
+	data, err := os.ReadFile(path.Join("testdata", file))
+	if err != nil {
+		return nil, err
+	}
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusNotFound
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(data))),
+		Request:    req,
+	}, nil
+}
+
+func newFixtureYFinance() *YFinance {
+	return &YFinance{
+		client: &http.Client{
+			Transport: &fixtureTransport{
+				files: map[string]string{
+					"/v8/finance/chart/AAPL":         "chart.json",
+					"/v10/finance/quoteSummary/AAPL": "quoteSummary.json",
+				},
+			},
+		},
+		secrets: &FileSecretStore{},
+		cache:   NewPriceHistoryCache(),
+	}
+}
+
+func TestFetchPriceHistoryFixture(t *testing.T) {
+	yf := newFixtureYFinance()
+	start := time.Date(2024, 7, 26, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 8, 3, 0, 0, 0, 0, time.UTC)
+	hist, err := yf.FetchPriceHistory("AAPL", start, end)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(hist) != 6 {
+		t.Fatalf("Wrong number of history items: want 6, got %d", len(hist))
+	}
+	last := hist[len(hist)-1]
+	if last.Currency != "USD" || last.Symbol != "AAPL" {
+		t.Errorf("Wrong quote: %+v", last)
+	}
+	if want := Micros(219_860_000); last.ClosingPrice-want > 1 || want-last.ClosingPrice > 1 {
+		t.Errorf("Wrong closing price: want %v, got %v", want, last.ClosingPrice)
+	}
+	if tz := last.Timestamp.Location().String(); tz != "America/New_York" {
+		t.Errorf("Wrong timezone: %s", tz)
+	}
+	if _, err := yf.FetchPriceHistory("DSNTEXST", start, end); !errors.Is(err, ErrTickerNotFound) {
+		t.Errorf("Expected ErrTickerNotFound, got %v", err)
+	}
+}
+
+func TestFetchQuoteSummaryFixture(t *testing.T) {
+	yf := newFixtureYFinance()
+	qs, err := yf.FetchQuoteSummary("AAPL")
+	if err != nil {
+		t.Fatalf("Failed to get quote summary: %v", err)
+	}
+	if tz := qs.ExchangeTimezone(); tz != "America/New_York" {
+		t.Errorf("Wrong exchange timezone: %q", tz)
+	}
+}
+
+func TestFakeQuoteProviderFromStore(t *testing.T) {
+	s, err := LoadStore("./testdata/testledger.json")
+	if err != nil {
+		t.Fatal("Cannot load store:", err)
+	}
+	f := NewFakeQuoteProviderFromStore(s)
+	date := time.Date(2024, 6, 3, 18, 0, 0, 0, time.UTC)
+	q, err := f.GetDailyQuote("NESN.SW", date)
+	if err != nil {
+		t.Fatal("GetDailyQuote failed:", err)
+	}
+	if q.ClosingPrice != 98*UnitValue || q.Currency != "CHF" || !q.Timestamp.Equal(date) {
+		t.Errorf("Wrong quote: %+v", q)
+	}
+	r, err := f.GetDailyExchangeRate("EUR", "CHF", date)
+	if err != nil {
+		t.Fatal("GetDailyExchangeRate failed:", err)
+	}
+	if r.ClosingPrice != 950_000 {
+		t.Errorf("Wrong exchange rate: %v", r.ClosingPrice)
+	}
+	if _, err := f.GetDailyQuote("MSFT", date); !errors.Is(err, ErrTickerNotFound) {
+		t.Errorf("Expected ErrTickerNotFound, got %v", err)
+	}
+}
+
 func TestGetDailyQuotesFuture(t *testing.T) {
 	os.Setenv(cookieJarEnvVar, path.Join("./testdata", "yfcookiejar.json"))
 	yf, err := NewYFinance()
@@ -2,8 +2,11 @@ package kontoo
 
 import (
 	"fmt"
+	"os"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 )
 
 type ReportingPeriod struct {
@@ -172,3 +175,465 @@ func (s *Store) QuarterlyReport(endDate Date, numPeriods int) *Report {
 	})
 	return report
 }
+
+// Default minimum number of days between two data points to be reported as a gap.
+const defaultMinGapDays = 14
+
+// DataGap is a period without price or exchange rate data
+// while the corresponding asset (or currency) was held.
+type DataGap struct {
+	AssetID string // Empty for exchange rate gaps.
+	Label   string // Asset name or currency pair.
+	// Start is the date of the last data point before the gap,
+	// or the start of the holding period if that is later.
+	Start Date
+	// End is the date of the first data point after the gap,
+	// or the end of the holding period if that is earlier.
+	End Date
+}
+
+func (g *DataGap) Days() int {
+	return int(g.End.Sub(g.Start.Time).Hours() / 24)
+}
+
+type dateRange struct {
+	start, end Date
+}
+
+// holdingPeriods returns the periods up to end during which the position
+// built from entries had a non-zero market value.
+func holdingPeriods(asset *Asset, entries []*LedgerEntry, end Date) []dateRange {
+	var res []dateRange
+	pos := &AssetPosition{
+		Asset: asset,
+	}
+	var start Date
+	for _, e := range entries {
+		if e.ValueDate.After(end.Time) {
+			break
+		}
+		held := pos.MarketValue() != 0
+		pos.Update(e)
+		if !held && pos.MarketValue() != 0 {
+			start = e.ValueDate
+		} else if held && pos.MarketValue() == 0 {
+			res = append(res, dateRange{start, e.ValueDate})
+		}
+	}
+	if pos.MarketValue() != 0 && !start.After(end.Time) {
+		res = append(res, dateRange{start, end})
+	}
+	return res
+}
+
+// mergeDateRanges merges overlapping ranges. The result is sorted by start date.
+func mergeDateRanges(rs []dateRange) []dateRange {
+	if len(rs) == 0 {
+		return nil
+	}
+	sorted := make([]dateRange, len(rs))
+	copy(sorted, rs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].start.Before(sorted[j].start.Time)
+	})
+	res := []dateRange{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &res[len(res)-1]
+		if r.start.After(last.end.Time) {
+			res = append(res, r)
+		} else if r.end.After(last.end.Time) {
+			last.end = r.end
+		}
+	}
+	return res
+}
+
+// findGaps returns all gaps of more than minDays days between the
+// (chronologically sorted) dates within the given periods.
+func findGaps(periods []dateRange, dates []Date, minDays int) []dateRange {
+	var res []dateRange
+	maxGap := time.Duration(minDays) * 24 * time.Hour
+	for _, p := range periods {
+		i := sort.Search(len(dates), func(i int) bool {
+			return dates[i].After(p.start.Time)
+		})
+		// The last data point at or before the start of the period
+		// counts as if it was at the start of the period.
+		prev := p.start
+		for ; i < len(dates) && !dates[i].After(p.end.Time); i++ {
+			if dates[i].Sub(prev.Time) > maxGap {
+				res = append(res, dateRange{prev, dates[i]})
+			}
+			prev = dates[i]
+		}
+		if p.end.Sub(prev.Time) > maxGap {
+			res = append(res, dateRange{prev, p.end})
+		}
+	}
+	return res
+}
+
+// DataGaps returns all gaps of more than minDays days in the price history
+// of assets and in the exchange rates of their currencies, up to end.
+// Only the periods during which an asset (or any asset in a currency) was held are considered.
+func (s *Store) DataGaps(end Date, minDays int) (priceGaps, rateGaps []*DataGap) {
+	currencyPeriods := make(map[Currency][]dateRange)
+	for _, a := range s.ledger.Assets {
+		id := a.ID()
+		periods := holdingPeriods(a, s.entries[id], end)
+		if len(periods) == 0 {
+			continue
+		}
+		if a.Currency != s.BaseCurrency() {
+			currencyPeriods[a.Currency] = append(currencyPeriods[a.Currency], periods...)
+		}
+		if !slices.Contains(a.Type.ValidEntryTypes(), AssetPrice) {
+			// No prices expected for this asset (e.g., accounts).
+			continue
+		}
+		var dates []Date
+		for _, e := range s.entries[id] {
+			if e.PriceMicros != 0 {
+				dates = append(dates, e.ValueDate)
+			}
+		}
+		for _, g := range findGaps(periods, dates, minDays) {
+			priceGaps = append(priceGaps, &DataGap{
+				AssetID: id,
+				Label:   a.Name,
+				Start:   g.start,
+				End:     g.end,
+			})
+		}
+	}
+	for c, ps := range currencyPeriods {
+		var dates []Date
+		for _, e := range s.exchangeRates[c] {
+			dates = append(dates, e.ValueDate)
+		}
+		for _, g := range findGaps(mergeDateRanges(ps), dates, minDays) {
+			rateGaps = append(rateGaps, &DataGap{
+				Label: string(s.BaseCurrency()) + "/" + string(c),
+				Start: g.start,
+				End:   g.end,
+			})
+		}
+	}
+	cmpGaps := func(a, b *DataGap) int {
+		if c := strings.Compare(strings.ToLower(a.Label), strings.ToLower(b.Label)); c != 0 {
+			return c
+		}
+		return a.Start.Compare(b.Start)
+	}
+	slices.SortFunc(priceGaps, cmpGaps)
+	slices.SortFunc(rateGaps, cmpGaps)
+	return priceGaps, rateGaps
+}
+
+// AssetEntryCount is the number of ledger entries of an asset.
+type AssetEntryCount struct {
+	Asset *Asset
+	Count int
+}
+
+// AssetTypeCount is the number of assets of an asset type.
+type AssetTypeCount struct {
+	Type  AssetType
+	Count int
+}
+
+// LedgerStats summarizes the contents of the ledger.
+type LedgerStats struct {
+	NumAssets    int
+	NumEntries   int
+	AssetsByType []*AssetTypeCount // Sorted by asset type.
+	// Entries per type and year: EntryCounts[i][j] is the number of
+	// entries of EntryTypes[i] with a value date in Years[j].
+	EntryTypes  []EntryType
+	Years       []int
+	EntryCounts [][]int
+	YearTotals  []int // Number of entries per year.
+	FirstDate   Date  // Earliest value date of any entry.
+	LastDate    Date  // Latest value date of any entry.
+	FileSize    int64 // Size of the ledger file in bytes, -1 if unknown.
+	// Assets with the most entries, in descending order.
+	TopAssets []*AssetEntryCount
+}
+
+// Stats returns statistics about the ledger. At most topN assets
+// are included in the TopAssets.
+func (s *Store) Stats(topN int) *LedgerStats {
+	st := &LedgerStats{
+		NumAssets:  len(s.ledger.Assets),
+		NumEntries: len(s.ledger.Entries),
+		FileSize:   -1,
+	}
+	if fi, err := os.Stat(s.path); err == nil {
+		st.FileSize = fi.Size()
+	}
+	assetTypes := make(map[AssetType]int)
+	for _, a := range s.ledger.Assets {
+		assetTypes[a.Type]++
+	}
+	for _, t := range AssetTypeValues() {
+		if n := assetTypes[t]; n > 0 {
+			st.AssetsByType = append(st.AssetsByType, &AssetTypeCount{Type: t, Count: n})
+		}
+	}
+	type typeYear struct {
+		t EntryType
+		y int
+	}
+	counts := make(map[typeYear]int)
+	entryTypes := make(map[EntryType]bool)
+	years := make(map[int]bool)
+	for _, e := range s.ledger.Entries {
+		y := e.ValueDate.Year()
+		counts[typeYear{e.Type, y}]++
+		entryTypes[e.Type] = true
+		years[y] = true
+		if st.FirstDate.IsZero() || e.ValueDate.Before(st.FirstDate.Time) {
+			st.FirstDate = e.ValueDate
+		}
+		if e.ValueDate.After(st.LastDate.Time) {
+			st.LastDate = e.ValueDate
+		}
+	}
+	for _, t := range EntryTypeValues() {
+		if entryTypes[t] {
+			st.EntryTypes = append(st.EntryTypes, t)
+		}
+	}
+	for y := range years {
+		st.Years = append(st.Years, y)
+	}
+	slices.Sort(st.Years)
+	st.YearTotals = make([]int, len(st.Years))
+	st.EntryCounts = make([][]int, len(st.EntryTypes))
+	for i, t := range st.EntryTypes {
+		st.EntryCounts[i] = make([]int, len(st.Years))
+		for j, y := range st.Years {
+			n := counts[typeYear{t, y}]
+			st.EntryCounts[i][j] = n
+			st.YearTotals[j] += n
+		}
+	}
+	for _, a := range s.ledger.Assets {
+		if n := len(s.entries[a.ID()]); n > 0 {
+			st.TopAssets = append(st.TopAssets, &AssetEntryCount{Asset: a, Count: n})
+		}
+	}
+	slices.SortStableFunc(st.TopAssets, func(a, b *AssetEntryCount) int {
+		return b.Count - a.Count
+	})
+	if len(st.TopAssets) > topN {
+		st.TopAssets = st.TopAssets[:topN]
+	}
+	return st
+}
+
+// CustodianPosition is the part of an asset position held at a custodian.
+type CustodianPosition struct {
+	Asset          *Asset
+	Depot          string
+	QuantityMicros Micros
+	// Market value in the asset's currency.
+	ValueMicros Micros
+	// Market value in the base currency.
+	ValueBaseCurrencyMicros Micros
+}
+
+// CustodianHoldings lists the positions held at a custodian.
+// All aggregate values are given in the base currency.
+type CustodianHoldings struct {
+	Custodian   *Custodian // nil for positions without a custodian.
+	Positions   []*CustodianPosition
+	ValueMicros Micros
+	// Estimated annual fees, based on the custodian's fees and the current value.
+	EstimatedFeeMicros Micros
+}
+
+// EstimatedFee returns the annual fee charged by c for holding the given value.
+func (c *Custodian) EstimatedFee(value Micros) Micros {
+	return c.AnnualFeeMicros + value.Mul(c.CustodyFeeMicros)
+}
+
+// CustodianHoldings returns the positions at date grouped by custodian, ordered
+// by custodian name. All custodians are included, even if they hold no positions.
+// Positions without a custodian are grouped last, under a nil Custodian.
+//
+// Positions split across depots are split between custodians: a depot listed
+// in a custodian's Depots is attributed to that custodian, all other depots
+// to the asset's custodian.
+// Positions for which no exchange rate to the base currency is known are ignored.
+func (s *Store) CustodianHoldings(date Date) []*CustodianHoldings {
+	custodians := s.Custodians()
+	res := make([]*CustodianHoldings, len(custodians), len(custodians)+1)
+	byID := make(map[string]*CustodianHoldings)
+	for i, c := range custodians {
+		res[i] = &CustodianHoldings{Custodian: c}
+		byID[c.ID] = res[i]
+	}
+	unassigned := &CustodianHoldings{}
+	add := func(custodianID string, p *CustodianPosition) {
+		h, ok := byID[custodianID]
+		if !ok {
+			h = unassigned
+		}
+		h.Positions = append(h.Positions, p)
+		h.ValueMicros += p.ValueBaseCurrencyMicros
+	}
+	for _, p := range s.AssetPositionsAt(date) {
+		rate, _, ok := s.ExchangeRateAt(p.Currency(), date)
+		if !ok {
+			continue
+		}
+		value := p.MarketValue()
+		depots := p.Depots()
+		if depots == nil || p.QuantityMicros == 0 {
+			add(p.Asset.Custodian, &CustodianPosition{
+				Asset:                   p.Asset,
+				QuantityMicros:          p.QuantityMicros,
+				ValueMicros:             value,
+				ValueBaseCurrencyMicros: value.Div(rate),
+			})
+			continue
+		}
+		for _, d := range depots {
+			custodianID := p.Asset.Custodian
+			if c := s.DepotCustodian(d.Depot); c != nil {
+				custodianID = c.ID
+			}
+			v := value.Frac(d.QuantityMicros, p.QuantityMicros)
+			add(custodianID, &CustodianPosition{
+				Asset:                   p.Asset,
+				Depot:                   d.Depot,
+				QuantityMicros:          d.QuantityMicros,
+				ValueMicros:             v,
+				ValueBaseCurrencyMicros: v.Div(rate),
+			})
+		}
+	}
+	if len(unassigned.Positions) > 0 {
+		res = append(res, unassigned)
+	}
+	for _, h := range res {
+		slices.SortFunc(h.Positions, func(a, b *CustodianPosition) int {
+			if c := strings.Compare(a.Asset.Name, b.Asset.Name); c != 0 {
+				return c
+			}
+			return strings.Compare(a.Depot, b.Depot)
+		})
+		if h.Custodian != nil {
+			h.EstimatedFeeMicros = h.Custodian.EstimatedFee(h.ValueMicros)
+		}
+	}
+	return res
+}
+
+// MonthlyIncome contains the interest and dividend income per calendar month
+// for consecutive years, converted to the base currency at the payment date.
+type MonthlyIncome struct {
+	Years []int
+	// InterestMicros[i][m] is the interest income in Years[i], month m+1.
+	InterestMicros [][]Micros
+	// DividendMicros[i][m] is the dividend income in Years[i], month m+1.
+	DividendMicros [][]Micros
+}
+
+// Total returns the total income in Years[i], month m+1.
+func (inc *MonthlyIncome) Total(i, m int) Micros {
+	return inc.InterestMicros[i][m] + inc.DividendMicros[i][m]
+}
+
+// MonthlyIncome returns the monthly income of the numYears years up to and
+// including endYear. Payments for which no exchange rate to the base currency
+// is known are ignored.
+func (s *Store) MonthlyIncome(endYear, numYears int) *MonthlyIncome {
+	inc := &MonthlyIncome{
+		Years:          make([]int, numYears),
+		InterestMicros: make([][]Micros, numYears),
+		DividendMicros: make([][]Micros, numYears),
+	}
+	startYear := endYear - numYears + 1
+	for i := range numYears {
+		inc.Years[i] = startYear + i
+		inc.InterestMicros[i] = make([]Micros, 12)
+		inc.DividendMicros[i] = make([]Micros, 12)
+	}
+	for _, e := range s.ledger.Entries {
+		if e.Type != InterestPayment && e.Type != DividendPayment {
+			continue
+		}
+		i := e.ValueDate.Year() - startYear
+		if i < 0 || i >= numYears {
+			continue
+		}
+		rate, _, ok := s.ExchangeRateAt(e.Currency, e.ValueDate)
+		if !ok {
+			continue
+		}
+		m := int(e.ValueDate.Month()) - 1
+		if e.Type == InterestPayment {
+			inc.InterestMicros[i][m] += e.ValueMicros.Div(rate)
+		} else {
+			inc.DividendMicros[i][m] += e.ValueMicros.Div(rate)
+		}
+	}
+	return inc
+}
+
+// interestBearing reports whether a counts towards the average interest rate.
+func interestBearing(a *Asset) bool {
+	cat := a.Category()
+	return cat == FixedIncome || cat == CashEquivalents
+}
+
+// AverageInterest returns the market value weighted average interest rate
+// of all fixed-income and cash positions at date, as well as their total
+// value in the base currency. Positions for which no exchange rate to the
+// base currency is known are ignored.
+func (s *Store) AverageInterest(date Date) (rate, value Micros) {
+	var weighted Micros
+	for _, p := range s.AssetPositionsAt(date) {
+		if !interestBearing(p.Asset) {
+			continue
+		}
+		xr, _, ok := s.ExchangeRateAt(p.Currency(), date)
+		if !ok {
+			continue
+		}
+		v := p.MarketValue().Div(xr)
+		value += v
+		weighted += p.Asset.InterestMicros.Mul(v)
+	}
+	if value <= 0 {
+		return 0, value
+	}
+	return weighted.Div(value), value
+}
+
+// InterestRatePoint is the average interest rate at a single date.
+type InterestRatePoint struct {
+	Date        Date
+	RateMicros  Micros
+	ValueMicros Micros // Value of all interest-bearing positions in the base currency.
+}
+
+// AverageInterestHistory returns the average interest rate at the end
+// of each month from start until end, and at end itself.
+func (s *Store) AverageInterestHistory(start, end Date) []*InterestRatePoint {
+	var res []*InterestRatePoint
+	add := func(d Date) {
+		rate, value := s.AverageInterest(d)
+		res = append(res, &InterestRatePoint{Date: d, RateMicros: rate, ValueMicros: value})
+	}
+	// Last day of start's month.
+	d := DateVal(start.Year(), start.Month()+1, 0)
+	for d.Before(end.Time) {
+		add(d)
+		d = DateVal(d.Year(), d.Month()+2, 0)
+	}
+	add(end)
+	return res
+}
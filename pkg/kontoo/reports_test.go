@@ -2,6 +2,7 @@ package kontoo
 
 import (
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -99,3 +100,268 @@ func TestReportEnsureLength(t *testing.T) {
 		}
 	}
 }
+
+func TestDataGaps(t *testing.T) {
+	l := &Ledger{
+		Header: &LedgerHeader{BaseCurrency: "EUR"},
+		Assets: []*Asset{
+			{
+				Name:     "Sparkonto",
+				Type:     SavingsAccount,
+				IBAN:     ibanDE100,
+				Currency: "EUR",
+			},
+			{
+				Name:         "Nestle",
+				Type:         Stock,
+				TickerSymbol: "NESN",
+				Currency:     "CHF",
+			},
+		},
+	}
+	s, err := NewStore(l, "/test")
+	if err != nil {
+		t.Fatal("Could not create store", err)
+	}
+	entries := []*LedgerEntry{
+		{Type: ExchangeRate, QuoteCurrency: "CHF", ValueDate: DateVal(2024, 1, 1), PriceMicros: 2 * UnitValue},
+		{Type: ExchangeRate, QuoteCurrency: "CHF", ValueDate: DateVal(2024, 2, 15), PriceMicros: 2 * UnitValue},
+		// Exchange rates outside the holding period are irrelevant.
+		{Type: ExchangeRate, QuoteCurrency: "CHF", ValueDate: DateVal(2024, 6, 1), PriceMicros: 2 * UnitValue},
+		{Type: AccountBalance, AssetID: ibanDE100, ValueDate: DateVal(2023, 1, 1), ValueMicros: 1000 * UnitValue},
+		{Type: AssetPurchase, AssetID: "NESN", ValueDate: DateVal(2024, 1, 1), QuantityMicros: 20 * UnitValue, PriceMicros: 100 * UnitValue},
+		{Type: AssetPrice, AssetID: "NESN", ValueDate: DateVal(2024, 1, 10), PriceMicros: 101 * UnitValue},
+		{Type: AssetPrice, AssetID: "NESN", ValueDate: DateVal(2024, 3, 1), PriceMicros: 102 * UnitValue},
+		{Type: AssetSale, AssetID: "NESN", ValueDate: DateVal(2024, 3, 10), QuantityMicros: -20 * UnitValue, PriceMicros: 103 * UnitValue},
+	}
+	for _, e := range entries {
+		if err := s.Add(e); err != nil {
+			t.Fatal("Cannot add to ledger:", err)
+		}
+	}
+	priceGaps, rateGaps := s.DataGaps(DateVal(2024, 6, 30), 14)
+	wantPriceGaps := []*DataGap{
+		{AssetID: "NESN", Label: "Nestle", Start: DateVal(2024, 1, 10), End: DateVal(2024, 3, 1)},
+	}
+	if diff := cmp.Diff(wantPriceGaps, priceGaps); diff != "" {
+		t.Errorf("Price gaps differ: (-want +got): %s", diff)
+	}
+	wantRateGaps := []*DataGap{
+		{Label: "EUR/CHF", Start: DateVal(2024, 1, 1), End: DateVal(2024, 2, 15)},
+		{Label: "EUR/CHF", Start: DateVal(2024, 2, 15), End: DateVal(2024, 3, 10)},
+	}
+	if diff := cmp.Diff(wantRateGaps, rateGaps); diff != "" {
+		t.Errorf("Exchange rate gaps differ: (-want +got): %s", diff)
+	}
+	if got := rateGaps[0].Days(); got != 45 {
+		t.Errorf("Wrong number of days: want 45, got %d", got)
+	}
+}
+
+func TestLedgerStats(t *testing.T) {
+	s, err := LoadStore("./testdata/testledger.json")
+	if err != nil {
+		t.Fatal("Cannot load store:", err)
+	}
+	st := s.Stats(2)
+	if st.NumAssets != 4 || st.NumEntries != 4 {
+		t.Errorf("Wrong counts: %d assets, %d entries", st.NumAssets, st.NumEntries)
+	}
+	if st.FileSize <= 0 {
+		t.Errorf("Wrong file size: %d", st.FileSize)
+	}
+	wantTypes := []*AssetTypeCount{
+		{Type: Stock, Count: 2},
+		{Type: GovernmentBond, Count: 1},
+		{Type: CheckingAccount, Count: 1},
+	}
+	// Sorted in the order of AssetType values.
+	slices.SortFunc(wantTypes, func(a, b *AssetTypeCount) int { return int(a.Type) - int(b.Type) })
+	if diff := cmp.Diff(wantTypes, st.AssetsByType); diff != "" {
+		t.Errorf("AssetsByType differs: (-want +got): %s", diff)
+	}
+	if diff := cmp.Diff([]int{2024}, st.Years); diff != "" {
+		t.Errorf("Years differ: (-want +got): %s", diff)
+	}
+	if diff := cmp.Diff([]int{4}, st.YearTotals); diff != "" {
+		t.Errorf("YearTotals differ: (-want +got): %s", diff)
+	}
+	for i, et := range st.EntryTypes {
+		want := 1
+		if et == AssetPurchase {
+			want = 2
+		}
+		if got := st.EntryCounts[i][0]; got != want {
+			t.Errorf("Wrong count for %v: want %d, got %d", et, want, got)
+		}
+	}
+	if !st.FirstDate.Equal(DateVal(2024, 1, 1)) || !st.LastDate.Equal(DateVal(2024, 1, 3)) {
+		t.Errorf("Wrong date range: %v - %v", st.FirstDate, st.LastDate)
+	}
+	if len(st.TopAssets) != 2 {
+		t.Errorf("Wrong number of top assets: %d", len(st.TopAssets))
+	}
+}
+
+func TestCustodianHoldings(t *testing.T) {
+	const u = UnitValue
+	s, err := NewStore(&Ledger{
+		Header: &LedgerHeader{BaseCurrency: "EUR"},
+		Custodians: []*Custodian{
+			{ID: "b", Name: "Bank B", Depots: []string{"b-depot"}},
+			{ID: "a", Name: "Bank A", CustodyFeeMicros: 1000, AnnualFeeMicros: 10 * u},
+			{ID: "c", Name: "Bank C", AnnualFeeMicros: 5 * u},
+		},
+		Assets: []*Asset{
+			{Type: Stock, Name: "X", TickerSymbol: "X", Currency: "EUR", Custodian: "a"},
+			{Type: Stock, Name: "Y", TickerSymbol: "Y", Currency: "EUR"},
+		},
+	}, "/test")
+	if err != nil {
+		t.Fatal("Could not create store:", err)
+	}
+	entries := []*LedgerEntry{
+		{Type: AssetPurchase, AssetID: "X", ValueDate: DateVal(2024, 1, 1), QuantityMicros: 10 * u, PriceMicros: 100 * u},
+		{Type: AssetPurchase, AssetID: "X", ValueDate: DateVal(2024, 1, 2), QuantityMicros: 5 * u, PriceMicros: 100 * u, Depot: "b-depot"},
+		// Depots are only attributed to custodians that list them, even if named after a custodian ID.
+		{Type: AssetPurchase, AssetID: "X", ValueDate: DateVal(2024, 1, 3), QuantityMicros: 2 * u, PriceMicros: 100 * u, Depot: "c"},
+		{Type: AssetPurchase, AssetID: "Y", ValueDate: DateVal(2024, 1, 1), QuantityMicros: 1 * u, PriceMicros: 50 * u},
+	}
+	for _, e := range entries {
+		if err := s.Add(e); err != nil {
+			t.Fatal("Cannot add entry:", err)
+		}
+	}
+	hs := s.CustodianHoldings(DateVal(2024, 2, 1))
+	type summary struct {
+		Custodian string
+		Assets    []string
+		Value     Micros
+		Fee       Micros
+	}
+	var got []summary
+	for _, h := range hs {
+		sm := summary{Value: h.ValueMicros, Fee: h.EstimatedFeeMicros}
+		if h.Custodian != nil {
+			sm.Custodian = h.Custodian.ID
+		}
+		for _, p := range h.Positions {
+			sm.Assets = append(sm.Assets, p.Asset.ID()+"/"+p.Depot)
+		}
+		got = append(got, sm)
+	}
+	want := []summary{
+		{Custodian: "a", Assets: []string{"X/", "X/c"}, Value: 1200 * u, Fee: 11*u + u/5},
+		{Custodian: "b", Assets: []string{"X/b-depot"}, Value: 500 * u},
+		{Custodian: "c", Fee: 5 * u},
+		{Assets: []string{"Y/"}, Value: 50 * u},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("CustodianHoldings differ: (-want +got): %s", diff)
+	}
+}
+
+func TestMonthlyIncome(t *testing.T) {
+	const u = UnitValue
+	s, err := NewStore(&Ledger{
+		Header: &LedgerHeader{BaseCurrency: "EUR"},
+		Assets: []*Asset{
+			{Type: SavingsAccount, Name: "Savings", IBAN: ibanDE100, Currency: "EUR"},
+			{Type: Stock, Name: "Nestle", TickerSymbol: "NESN", Currency: "CHF"},
+		},
+	}, "/test")
+	if err != nil {
+		t.Fatal("Could not create store:", err)
+	}
+	entries := []*LedgerEntry{
+		{Type: ExchangeRate, Currency: "EUR", QuoteCurrency: "CHF", ValueDate: DateVal(2023, 1, 1), PriceMicros: 2 * u},
+		{Type: InterestPayment, AssetID: ibanDE100, Currency: "EUR", ValueDate: DateVal(2022, 12, 31), ValueMicros: 99 * u},
+		{Type: InterestPayment, AssetID: ibanDE100, Currency: "EUR", ValueDate: DateVal(2023, 3, 31), ValueMicros: 10 * u},
+		{Type: InterestPayment, AssetID: ibanDE100, Currency: "EUR", ValueDate: DateVal(2024, 3, 1), ValueMicros: 20 * u},
+		{Type: InterestPayment, AssetID: ibanDE100, Currency: "EUR", ValueDate: DateVal(2024, 3, 31), ValueMicros: 5 * u},
+		{Type: DividendPayment, AssetID: "NESN", Currency: "CHF", ValueDate: DateVal(2024, 4, 20), ValueMicros: 30 * u},
+	}
+	for _, e := range entries {
+		if err := s.Add(e); err != nil {
+			t.Fatal("Cannot add entry:", err)
+		}
+	}
+	inc := s.MonthlyIncome(2024, 2)
+	if diff := cmp.Diff([]int{2023, 2024}, inc.Years); diff != "" {
+		t.Errorf("Wrong years (-want +got): %s", diff)
+	}
+	if got := inc.InterestMicros[0][2]; got != 10*u {
+		t.Errorf("Wrong interest in 2023-03: %v", got)
+	}
+	if got := inc.InterestMicros[1][2]; got != 25*u {
+		t.Errorf("Wrong interest in 2024-03: %v", got)
+	}
+	// Dividend in CHF is converted to EUR.
+	if got := inc.DividendMicros[1][3]; got != 15*u {
+		t.Errorf("Wrong dividends in 2024-04: %v", got)
+	}
+	if got := inc.Total(1, 3); got != 15*u {
+		t.Errorf("Wrong total in 2024-04: %v", got)
+	}
+	var sum Micros
+	for i := range inc.Years {
+		for m := range 12 {
+			sum += inc.Total(i, m)
+		}
+	}
+	if sum != 50*u {
+		t.Errorf("Wrong total income: want 50, got %v", sum)
+	}
+}
+
+func TestAverageInterest(t *testing.T) {
+	const u = UnitValue
+	s, err := NewStore(&Ledger{
+		Header: &LedgerHeader{BaseCurrency: "EUR"},
+		Assets: []*Asset{
+			{Type: SavingsAccount, Name: "Savings EUR", IBAN: ibanDE100, Currency: "EUR", InterestMicros: 20_000},
+			{Type: SavingsAccount, Name: "Savings CHF", IBAN: ibanDE999, Currency: "CHF", InterestMicros: 40_000},
+			{Type: Stock, Name: "Nestle", TickerSymbol: "NESN", Currency: "CHF"},
+		},
+	}, "/test")
+	if err != nil {
+		t.Fatal("Could not create store:", err)
+	}
+	entries := []*LedgerEntry{
+		{Type: ExchangeRate, Currency: "EUR", QuoteCurrency: "CHF", ValueDate: DateVal(2023, 1, 1), PriceMicros: 2 * u},
+		{Type: AccountBalance, AssetID: ibanDE100, ValueDate: DateVal(2024, 1, 1), ValueMicros: 1000 * u},
+		{Type: AccountBalance, AssetID: ibanDE999, ValueDate: DateVal(2024, 3, 15), ValueMicros: 2000 * u},
+		{Type: AssetPurchase, AssetID: "NESN", ValueDate: DateVal(2024, 1, 1), QuantityMicros: 10 * u, PriceMicros: 100 * u},
+	}
+	for _, e := range entries {
+		if err := s.Add(e); err != nil {
+			t.Fatal("Cannot add entry:", err)
+		}
+	}
+	// The CHF account is worth 1000 EUR, so both accounts have equal weight.
+	rate, value := s.AverageInterest(DateVal(2024, 6, 30))
+	if rate != 30_000 {
+		t.Errorf("Wrong average interest: want 3%%, got %v", rate)
+	}
+	if value != 2000*u {
+		t.Errorf("Wrong value: want 2000, got %v", value)
+	}
+	if rate, _ := s.AverageInterest(DateVal(2023, 6, 30)); rate != 0 {
+		t.Errorf("Wrong average interest without positions: %v", rate)
+	}
+
+	hist := s.AverageInterestHistory(DateVal(2024, 1, 15), DateVal(2024, 4, 10))
+	var dates []Date
+	var rates []Micros
+	for _, p := range hist {
+		dates = append(dates, p.Date)
+		rates = append(rates, p.RateMicros)
+	}
+	wantDates := []Date{DateVal(2024, 1, 31), DateVal(2024, 2, 29), DateVal(2024, 3, 31), DateVal(2024, 4, 10)}
+	if diff := cmp.Diff(wantDates, dates); diff != "" {
+		t.Errorf("Wrong dates (-want +got): %s", diff)
+	}
+	if diff := cmp.Diff([]Micros{20_000, 20_000, 30_000, 30_000}, rates); diff != "" {
+		t.Errorf("Wrong rates (-want +got): %s", diff)
+	}
+}
@@ -0,0 +1,110 @@
+package kontoo
+
+import (
+	"slices"
+)
+
+// StressScenario describes a hypothetical market shock that is
+// applied to the current portfolio.
+type StressScenario struct {
+	Name string
+	// Relative price change of equity positions, e.g. -0.30 for a 30% drop.
+	EquityShock Micros
+	// Absolute change of interest rates, e.g. 0.02 for +2 percentage points.
+	// It is applied to marketable fixed-income positions via their duration.
+	RateShock Micros
+	// Relative change of all foreign currencies against the base currency.
+	// 0.10 means that foreign currencies appreciate by 10%.
+	FXShock Micros
+}
+
+var (
+	defaultStressScenarios = []*StressScenario{
+		{Name: "Equities -30%", EquityShock: -300_000},
+		{Name: "Rates +2%", RateShock: 20_000},
+		{Name: "FX +10%", FXShock: 100_000},
+		{Name: "FX -10%", FXShock: -100_000},
+	}
+)
+
+// StressTestReport contains the hypothetical impact of a set of stress
+// scenarios on the portfolio, broken down by asset category.
+// All values are given in the base currency.
+type StressTestReport struct {
+	Scenarios  []*StressScenario
+	Categories []AssetCategory
+	Values     []Micros // Current value per category.
+	TotalValue Micros
+	// Impacts[i][j] is the impact of scenario i on category j.
+	Impacts      [][]Micros
+	TotalImpacts []Micros // Total impact per scenario.
+}
+
+// TotalImpactRatio returns the impact of scenario i relative to the total portfolio value.
+func (r *StressTestReport) TotalImpactRatio(i int) Micros {
+	if r.TotalValue == 0 {
+		return 0
+	}
+	return r.TotalImpacts[i].Div(r.TotalValue)
+}
+
+// shockedValue returns the value of position p (in the position's currency)
+// after applying scenario sc.
+func shockedValue(p *AssetPosition, sc *StressScenario, date Date) Micros {
+	value := p.MarketValue()
+	switch p.Asset.Category() {
+	case Equity:
+		return value + value.Mul(sc.EquityShock)
+	case FixedIncome:
+		if p.QuantityMicros == 0 {
+			// Account-based (e.g. fixed deposits): not marked to market.
+			return value
+		}
+		d := FloatAsMicros(modifiedDuration(p.Asset, date))
+		return value - value.Mul(d).Mul(sc.RateShock)
+	}
+	return value
+}
+
+// StressTest applies the given scenarios to all asset positions at date.
+// Positions for which no exchange rate to the base currency is known are ignored.
+func (s *Store) StressTest(date Date, scenarios []*StressScenario) *StressTestReport {
+	positions := s.AssetPositionsAt(date)
+	slices.SortFunc(positions, func(a, b *AssetPosition) int {
+		return int(s.ReportingCategory(a.Asset, date)) - int(s.ReportingCategory(b.Asset, date))
+	})
+	r := &StressTestReport{
+		Scenarios:    scenarios,
+		Impacts:      make([][]Micros, len(scenarios)),
+		TotalImpacts: make([]Micros, len(scenarios)),
+	}
+	for _, p := range positions {
+		rate, _, ok := s.ExchangeRateAt(p.Currency(), date)
+		if !ok {
+			continue
+		}
+		cat := s.ReportingCategory(p.Asset, date)
+		j := len(r.Categories) - 1
+		if j < 0 || r.Categories[j] != cat {
+			r.Categories = append(r.Categories, cat)
+			r.Values = append(r.Values, 0)
+			for i := range r.Impacts {
+				r.Impacts[i] = append(r.Impacts[i], 0)
+			}
+			j++
+		}
+		valueBC := p.MarketValue().Div(rate)
+		r.Values[j] += valueBC
+		r.TotalValue += valueBC
+		for i, sc := range scenarios {
+			v := shockedValue(p, sc, date)
+			if p.Currency() != s.BaseCurrency() {
+				v = v.Mul(UnitValue + sc.FXShock)
+			}
+			impact := v.Div(rate) - valueBC
+			r.Impacts[i][j] += impact
+			r.TotalImpacts[i] += impact
+		}
+	}
+	return r
+}
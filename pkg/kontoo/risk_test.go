@@ -0,0 +1,102 @@
+package kontoo
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStressTest(t *testing.T) {
+	l := &Ledger{
+		Header: &LedgerHeader{BaseCurrency: "EUR"},
+		Assets: []*Asset{
+			{
+				Name:     "Sparkonto",
+				Type:     SavingsAccount,
+				IBAN:     ibanDE100,
+				Currency: "EUR",
+			},
+			{
+				Name:         "Nestle",
+				Type:         Stock,
+				TickerSymbol: "NESN",
+				Currency:     "CHF",
+			},
+			{
+				Name:         "BUND",
+				Type:         GovernmentBond,
+				ISIN:         "DE99",
+				MaturityDate: newDate(2029, 1, 1),
+				Currency:     "EUR",
+			},
+		},
+	}
+	s, err := NewStore(l, "/test")
+	if err != nil {
+		t.Fatal("Could not create store", err)
+	}
+	entries := []*LedgerEntry{
+		{
+			Type:          ExchangeRate,
+			QuoteCurrency: "CHF",
+			ValueDate:     DateVal(2024, 1, 1),
+			PriceMicros:   2 * UnitValue,
+		},
+		{
+			Type:        AccountBalance,
+			AssetID:     ibanDE100,
+			ValueDate:   DateVal(2024, 1, 1),
+			ValueMicros: 1000 * UnitValue,
+		},
+		{
+			Type:           AssetPurchase,
+			AssetID:        "NESN",
+			ValueDate:      DateVal(2024, 1, 1),
+			QuantityMicros: 20 * UnitValue,
+			PriceMicros:    100 * UnitValue,
+		},
+		{
+			Type:           AssetPurchase,
+			AssetID:        "DE99",
+			ValueDate:      DateVal(2024, 1, 1),
+			QuantityMicros: 1000 * UnitValue,
+			PriceMicros:    1 * UnitValue,
+		},
+	}
+	for _, e := range entries {
+		if err := s.Add(e); err != nil {
+			t.Fatal("Cannot add to ledger:", err)
+		}
+	}
+	scenarios := []*StressScenario{
+		{Name: "Equities", EquityShock: -300_000},
+		{Name: "Rates", RateShock: 10_000},
+		{Name: "FX", FXShock: 100_000},
+	}
+	r := s.StressTest(DateVal(2024, 1, 1), scenarios)
+	if diff := cmp.Diff([]AssetCategory{Equity, FixedIncome, CashEquivalents}, r.Categories); diff != "" {
+		t.Errorf("Categories differ: (-want +got): %s", diff)
+	}
+	if diff := cmp.Diff([]Micros{1000 * UnitValue, 1000 * UnitValue, 1000 * UnitValue}, r.Values); diff != "" {
+		t.Errorf("Values differ: (-want +got): %s", diff)
+	}
+	wantImpacts := [][]Micros{
+		// Equities -30% of 2000 CHF == -600 CHF == -300 EUR.
+		{-300 * UnitValue, 0, 0},
+		// Zero coupon bond with 5y to maturity loses ~5% for a +1% rate change.
+		{0, -50 * UnitValue, 0},
+		// CHF appreciates by 10%.
+		{100 * UnitValue, 0, 0},
+	}
+	// The bond's duration is only approximately 5y due to leap years.
+	opt := cmp.Comparer(func(a, b Micros) bool {
+		d := a - b
+		return d > -UnitValue && d < UnitValue
+	})
+	if diff := cmp.Diff(wantImpacts, r.Impacts, opt); diff != "" {
+		t.Errorf("Impacts differ: (-want +got): %s", diff)
+	}
+	if diff := cmp.Diff([]Micros{-300 * UnitValue, -50 * UnitValue, 100 * UnitValue}, r.TotalImpacts, opt); diff != "" {
+		t.Errorf("Total impacts differ: (-want +got): %s", diff)
+	}
+}
@@ -0,0 +1,157 @@
+package kontoo
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// SearchHit is a single result of a site-wide search.
+type SearchHit struct {
+	Kind   string `json:"kind"` // One of "asset", "entry", "page".
+	Title  string `json:"title"`
+	Detail string `json:"detail,omitempty"`
+	URL    string `json:"url"`
+	Score  int    `json:"score"`
+}
+
+// scoreLower returns how well s matches t, which is expected to be in lower case:
+// 3 for an exact match, 2 for a prefix match, 1 if s contains t, and 0 otherwise.
+func scoreLower(s, t string) int {
+	s = strings.ToLower(s)
+	switch {
+	case s == "":
+		return 0
+	case s == t:
+		return 3
+	case strings.HasPrefix(s, t):
+		return 2
+	case strings.Contains(s, t):
+		return 1
+	}
+	return 0
+}
+
+// scoreAsset returns how well asset a matches the lower case term t.
+// Matches in identifiers count more than matches in names, which count
+// more than matches in the comment.
+func scoreAsset(t string, a *Asset) int {
+	score := 0
+	for _, id := range []string{a.CustomID, a.ISIN, a.IBAN, a.AccountNumber, a.WKN, a.TickerSymbol} {
+		score = max(score, 10*scoreLower(id, t))
+	}
+	for _, s := range a.QuoteServiceSymbols {
+		score = max(score, 10*scoreLower(s, t))
+	}
+	score = max(score, 8*scoreLower(a.Name, t), 8*scoreLower(a.ShortName, t))
+	if score == 0 && matchLower(a.Comment, t) {
+		score = 1
+	}
+	return score
+}
+
+// scoreEntry returns how well the ledger entry row e matches the lower case term t.
+// The entry's asset is not considered, so that asset hits rank higher.
+func scoreEntry(t string, e *LedgerEntryRow) int {
+	score := max(
+		4*scoreLower(e.Label(), t),
+		2*scoreLower(e.EntryType().String(), t),
+		2*scoreLower(e.Depot(), t),
+		2*scoreLower(e.ValueDate().String(), t),
+	)
+	if matchLower(e.Comment(), t) {
+		score = max(score, 2)
+	}
+	return score
+}
+
+// scoreAll returns the sum of the scores of all terms,
+// or 0 if any of the terms does not match.
+func scoreAll(terms []string, score func(t string) int) int {
+	total := 0
+	for _, t := range terms {
+		s := score(t)
+		if s == 0 {
+			return 0
+		}
+		total += s
+	}
+	return total
+}
+
+// Search returns the assets, ledger entries, and report pages that match
+// all whitespace-separated terms in query, ordered by descending relevance.
+// At most limit hits are returned.
+func (s *Server) Search(query string, limit int) []*SearchHit {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return nil
+	}
+	store := s.Store()
+	var hits []*SearchHit
+	for _, a := range store.ledger.Assets {
+		score := scoreAll(terms, func(t string) int { return scoreAsset(t, a) })
+		if score == 0 {
+			continue
+		}
+		hits = append(hits, &SearchHit{
+			Kind:   "asset",
+			Title:  a.Name,
+			Detail: fmt.Sprintf("%s · %s", a.ID(), a.Type.DisplayName()),
+			URL:    newURL("/kontoo/ledger", url.Values{"q": {"id:" + a.ID()}}).String(),
+			Score:  score,
+		})
+	}
+	for _, e := range store.ledger.Entries {
+		row := &LedgerEntryRow{E: e, A: store.assets[e.AssetID]}
+		score := scoreAll(terms, func(t string) int { return scoreEntry(t, row) })
+		if score == 0 {
+			continue
+		}
+		title := row.Label()
+		if title == "" {
+			title = e.Type.String()
+		}
+		hits = append(hits, &SearchHit{
+			Kind:   "entry",
+			Title:  fmt.Sprintf("#%d %s", e.SequenceNum, title),
+			Detail: fmt.Sprintf("%s · %s", e.ValueDate, e.Type),
+			URL:    newURL("/kontoo/ledger", url.Values{"q": {fmt.Sprintf("num:%d", e.SequenceNum)}}).String(),
+			Score:  score,
+		})
+	}
+	for i := range sitePages {
+		p := &sitePages[i]
+		if !s.pageVisible(p) {
+			continue
+		}
+		score := scoreAll(terms, func(t string) int {
+			sc := 0
+			for _, w := range strings.Fields(p.title) {
+				sc = max(sc, 6*scoreLower(w, t))
+			}
+			for _, k := range p.keywords {
+				sc = max(sc, 3*scoreLower(k, t))
+			}
+			return sc
+		})
+		if score == 0 {
+			continue
+		}
+		hits = append(hits, &SearchHit{
+			Kind:  "page",
+			Title: p.title,
+			URL:   p.path,
+			Score: score,
+		})
+	}
+	slices.SortStableFunc(hits, func(a, b *SearchHit) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
@@ -0,0 +1,97 @@
+package kontoo
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScoreLower(t *testing.T) {
+	tests := []struct {
+		s, t string
+		want int
+	}{
+		{"NESN", "nesn", 3},
+		{"Nestle SA", "nest", 2},
+		{"Nestle SA", "sa", 1},
+		{"Nestle SA", "novartis", 0},
+		{"", "x", 0},
+	}
+	for _, tc := range tests {
+		if got := scoreLower(tc.s, tc.t); got != tc.want {
+			t.Errorf("scoreLower(%q, %q): want %d, got %d", tc.s, tc.t, tc.want, got)
+		}
+	}
+}
+
+func TestServerSearch(t *testing.T) {
+	s := newTestServer(t)
+	kinds := func(hits []*SearchHit) []string {
+		var res []string
+		for _, h := range hits {
+			res = append(res, h.Kind+":"+h.Title)
+		}
+		return res
+	}
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		// Assets rank above their entries.
+		{"nestle", []string{"asset:Nestle SA", "entry:#3 Nestle SA"}},
+		{"NESN", []string{"asset:Nestle SA"}},
+		// Matches in page titles rank above matches in asset comments.
+		{"csv", []string{"page:Upload CSV", "asset:Mercedes-Benz Group"}},
+		// All terms must match.
+		{"nestle purchase", []string{"entry:#3 Nestle SA"}},
+		{"2024-01-03", []string{"entry:#4 10-Year T-Note Futures"}},
+		{"nonexistent", nil},
+	}
+	for _, tc := range tests {
+		got := kinds(s.Search(tc.q, 10))
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("Wrong hits for %q (-want +got): %s", tc.q, diff)
+		}
+	}
+	if hits := s.Search("a", 2); len(hits) != 2 {
+		t.Errorf("Limit not respected: got %d hits", len(hits))
+	}
+	// Hidden pages are not found.
+	s.Store().ledger.Header.Settings = &LedgerSettings{HiddenPages: []string{"uploadCSV"}}
+	if diff := cmp.Diff([]string{"asset:Mercedes-Benz Group"}, kinds(s.Search("csv", 10))); diff != "" {
+		t.Errorf("Wrong hits with hidden page (-want +got): %s", diff)
+	}
+}
+
+func TestHandleSearch(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Close()
+	get := func(query string) SearchResponse {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/kontoo/api/search?" + query)
+		if err != nil {
+			t.Fatal("Get failed:", err)
+		}
+		defer resp.Body.Close()
+		var r SearchResponse
+		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+			t.Fatal("Cannot decode response:", err)
+		}
+		return r
+	}
+	r := get("q=nestle")
+	if r.Status != StatusOK {
+		t.Fatalf("Wrong status: %v. Error: %q", r.Status, r.Error)
+	}
+	if len(r.Hits) == 0 || r.Hits[0].URL != "/kontoo/ledger?q=id%3ANESN" {
+		t.Errorf("Wrong hits: %+v", r.Hits)
+	}
+	if r := get("q=+"); r.Status != StatusInvalidArgument {
+		t.Errorf("Wrong status for empty query: %v", r.Status)
+	}
+	if r := get("q=nestle&limit=x"); r.Status != StatusInvalidArgument {
+		t.Errorf("Wrong status for invalid limit: %v", r.Status)
+	}
+}
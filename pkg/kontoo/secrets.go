@@ -0,0 +1,205 @@
+package kontoo
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+)
+
+// SecretStore stores small pieces of sensitive data, such as
+// the Y! Finance cookie jar or API keys of quote services.
+type SecretStore interface {
+	// Get returns the secret stored under key.
+	// It returns an error wrapping ErrSecretNotFound if no such secret exists.
+	Get(key string) ([]byte, error)
+	// Put stores value under key, replacing any previous value.
+	Put(key string, value []byte) error
+}
+
+var (
+	ErrSecretNotFound = errors.New("secret not found")
+	ErrReadOnlyStore  = errors.New("secret store is read-only")
+)
+
+const (
+	// Selects the SecretStore implementation: "file" (default), "env", or "keyring".
+	secretStoreEnvVar = "KONTOO_SECRET_STORE"
+	// Prefix of environment variables read by the "env" secret store.
+	secretEnvVarPrefix = "KONTOO_SECRET_"
+	// Service name under which secrets are stored in the OS keyring.
+	keyringService = "kontoo"
+	// Key of the Y! Finance cookie jar.
+	yfCookieJarKey = "yfcookiejar"
+)
+
+// FileSecretStore stores each secret in its own file that is only
+// readable by the current user.
+type FileSecretStore struct {
+	// Directory in which secrets are stored as "." + key.
+	Dir string
+	// Optional file paths for individual keys, overriding the default location.
+	Files map[string]string
+}
+
+func (s *FileSecretStore) path(key string) string {
+	if f, ok := s.Files[key]; ok {
+		return f
+	}
+	return path.Join(s.Dir, "."+key)
+}
+
+func (s *FileSecretStore) Get(key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, key)
+	}
+	return data, err
+}
+
+func (s *FileSecretStore) Put(key string, value []byte) error {
+	p := s.path(key)
+	if err := os.WriteFile(p, value, 0600); err != nil {
+		return err
+	}
+	// WriteFile does not change the permissions of existing files,
+	// which might have been created world-readable by older versions.
+	return os.Chmod(p, 0600)
+}
+
+// EnvSecretStore reads secrets from environment variables.
+// The variable name for a key is Prefix + key in upper case,
+// e.g. KONTOO_SECRET_YFCOOKIEJAR. Secrets cannot be stored.
+type EnvSecretStore struct {
+	Prefix string
+}
+
+func (s *EnvSecretStore) envVar(key string) string {
+	return s.Prefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+func (s *EnvSecretStore) Get(key string) ([]byte, error) {
+	v, ok := os.LookupEnv(s.envVar(key))
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, key)
+	}
+	return []byte(v), nil
+}
+
+func (s *EnvSecretStore) Put(key string, value []byte) error {
+	return fmt.Errorf("%w: cannot store %s, set %s instead", ErrReadOnlyStore, key, s.envVar(key))
+}
+
+// KeyringSecretStore stores secrets in the OS keyring. It uses the
+// security tool on macOS and secret-tool (libsecret) on Linux.
+type KeyringSecretStore struct {
+	Service string
+}
+
+func (s *KeyringSecretStore) Get(key string) ([]byte, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", s.Service, "-a", key, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", s.Service, "account", key)
+	default:
+		return nil, fmt.Errorf("keyring not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			stderr := bytes.TrimSpace(exitErr.Stderr)
+			if keyringItemNotFound(runtime.GOOS, exitErr.ExitCode(), stderr) {
+				return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, key)
+			}
+			return nil, fmt.Errorf("keyring lookup failed: %w (%s)", err, stderr)
+		}
+		return nil, fmt.Errorf("keyring lookup failed: %w", err)
+	}
+	// security appends a newline to the password.
+	return bytes.TrimSuffix(out, []byte("\n")), nil
+}
+
+// Exit status of security if the item could not be found (errSecItemNotFound).
+const securityItemNotFound = 44
+
+// keyringItemNotFound reports whether a failed keyring lookup on goos failed
+// because the item does not exist, as opposed to e.g. a locked keychain.
+func keyringItemNotFound(goos string, exitCode int, stderr []byte) bool {
+	switch goos {
+	case "darwin":
+		return exitCode == securityItemNotFound
+	case "linux":
+		// secret-tool exits with status 1 and no message if the item does not exist.
+		return exitCode == 1 && len(stderr) == 0
+	}
+	return false
+}
+
+// keyringPutCommand returns the command that stores value in the keyring of goos.
+// The value is always passed on stdin: command line arguments can be read by
+// any local user (e.g. using ps) while the command runs.
+func keyringPutCommand(goos, service, key string, value []byte) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	switch goos {
+	case "darwin":
+		// In interactive mode (-i), security reads commands from stdin.
+		// -X passes the value hex-encoded, which avoids any quoting issues.
+		// -U updates an existing item.
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %q -a %q -X %s\n",
+			service, key, hex.EncodeToString(value)))
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label", service+" "+key, "service", service, "account", key)
+		cmd.Stdin = bytes.NewReader(value)
+	default:
+		return nil, fmt.Errorf("keyring not supported on %s", goos)
+	}
+	return cmd, nil
+}
+
+func (s *KeyringSecretStore) Put(key string, value []byte) error {
+	cmd, err := keyringPutCommand(runtime.GOOS, s.Service, key, value)
+	if err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("keyring store failed: %w (%s)", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// NewSecretStoreFromEnv returns the SecretStore selected by the KONTOO_SECRET_STORE
+// environment variable. The default is a FileSecretStore in the user's home directory.
+// For backwards compatibility, the YFCOOKIEJAR variable still overrides the
+// location of the cookie jar file.
+func NewSecretStoreFromEnv() (SecretStore, error) {
+	switch kind := os.Getenv(secretStoreEnvVar); kind {
+	case "", "file":
+		dir := "."
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = home
+		}
+		s := &FileSecretStore{
+			Dir:   dir,
+			Files: make(map[string]string),
+		}
+		if f := os.Getenv(cookieJarEnvVar); f != "" {
+			s.Files[yfCookieJarKey] = f
+		}
+		return s, nil
+	case "env":
+		return &EnvSecretStore{Prefix: secretEnvVarPrefix}, nil
+	case "keyring":
+		return &KeyringSecretStore{Service: keyringService}, nil
+	default:
+		return nil, fmt.Errorf("invalid value for %s: %q", secretStoreEnvVar, kind)
+	}
+}
@@ -0,0 +1,163 @@
+package kontoo
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestFileSecretStore(t *testing.T) {
+	dir := t.TempDir()
+	s := &FileSecretStore{
+		Dir: dir,
+		Files: map[string]string{
+			"other": path.Join(dir, "other.json"),
+		},
+	}
+	if _, err := s.Get("apikey"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("Expected ErrSecretNotFound, got %v", err)
+	}
+	// Pre-existing files should become private.
+	if err := os.WriteFile(path.Join(dir, ".apikey"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"apikey", "other"} {
+		if err := s.Put(key, []byte("secret-"+key)); err != nil {
+			t.Fatalf("Put(%q) failed: %v", key, err)
+		}
+		got, err := s.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%q) failed: %v", key, err)
+		}
+		if string(got) != "secret-"+key {
+			t.Errorf("Wrong secret for %q: %q", key, got)
+		}
+	}
+	for _, f := range []string{".apikey", "other.json"} {
+		fi, err := os.Stat(path.Join(dir, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := fi.Mode().Perm(); perm != 0600 {
+			t.Errorf("Wrong permissions for %s: %o", f, perm)
+		}
+	}
+}
+
+func TestEnvSecretStore(t *testing.T) {
+	t.Setenv("KONTOO_SECRET_YFCOOKIEJAR", `{"Crumb":"abc"}`)
+	s := &EnvSecretStore{Prefix: secretEnvVarPrefix}
+	got, err := s.Get(yfCookieJarKey)
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	if string(got) != `{"Crumb":"abc"}` {
+		t.Errorf("Wrong secret: %q", got)
+	}
+	if _, err := s.Get("nonexistent"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+	if err := s.Put(yfCookieJarKey, []byte("x")); !errors.Is(err, ErrReadOnlyStore) {
+		t.Errorf("Expected ErrReadOnlyStore, got %v", err)
+	}
+}
+
+func TestKeyringPutCommandKeepsValueOffArgv(t *testing.T) {
+	value := []byte(`{"Crumb":"s3cr3t"}`)
+	for _, goos := range []string{"darwin", "linux"} {
+		t.Run(goos, func(t *testing.T) {
+			cmd, err := keyringPutCommand(goos, "kontoo", yfCookieJarKey, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, arg := range cmd.Args {
+				if strings.Contains(arg, "s3cr3t") || strings.Contains(arg, hex.EncodeToString(value)) {
+					t.Errorf("Secret value found in argv: %q", cmd.Args)
+				}
+			}
+			if cmd.Stdin == nil {
+				t.Fatal("Secret value not passed on stdin")
+			}
+			in, err := io.ReadAll(cmd.Stdin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(in), "s3cr3t") && !strings.Contains(string(in), hex.EncodeToString(value)) {
+				t.Errorf("Secret value not found on stdin: %q", in)
+			}
+		})
+	}
+	if _, err := keyringPutCommand("plan9", "kontoo", yfCookieJarKey, value); err == nil {
+		t.Error("Expected error for unsupported OS")
+	}
+}
+
+func TestKeyringItemNotFound(t *testing.T) {
+	tests := []struct {
+		goos     string
+		exitCode int
+		stderr   string
+		want     bool
+	}{
+		{"darwin", 44, "security: SecKeychainSearchCopyNext: The specified item could not be found in the keychain.", true},
+		{"darwin", 36, "security: SecKeychainSearchCopyNext: User interaction is not allowed.", false},
+		{"darwin", 51, "", false},
+		{"linux", 1, "", true},
+		{"linux", 1, "secret-tool: Cannot autolaunch D-Bus without X11 $DISPLAY", false},
+		{"linux", 2, "", false},
+		{"plan9", 1, "", false},
+	}
+	for _, tc := range tests {
+		if got := keyringItemNotFound(tc.goos, tc.exitCode, []byte(tc.stderr)); got != tc.want {
+			t.Errorf("keyringItemNotFound(%q, %d, %q) = %v, want %v", tc.goos, tc.exitCode, tc.stderr, got, tc.want)
+		}
+	}
+}
+
+func TestNewSecretStoreFromEnv(t *testing.T) {
+	jar := path.Join(t.TempDir(), "jar.json")
+	t.Setenv(cookieJarEnvVar, jar)
+	tests := []struct {
+		kind    string
+		want    string
+		wantErr bool
+	}{
+		{"", "file", false},
+		{"file", "file", false},
+		{"env", "env", false},
+		{"keyring", "keyring", false},
+		{"vault", "", true},
+	}
+	for _, tc := range tests {
+		t.Setenv(secretStoreEnvVar, tc.kind)
+		s, err := NewSecretStoreFromEnv()
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("Expected error for %q", tc.kind)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", tc.kind, err)
+		}
+		var got string
+		switch st := s.(type) {
+		case *FileSecretStore:
+			got = "file"
+			if f := st.path(yfCookieJarKey); f != jar {
+				t.Errorf("Cookie jar file override not honored: %q", f)
+			}
+		case *EnvSecretStore:
+			got = "env"
+		case *KeyringSecretStore:
+			got = "keyring"
+		}
+		if got != tc.want {
+			t.Errorf("Wrong store for %q: want %s, got %s", tc.kind, tc.want, got)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package kontoo
 import (
 	"bytes"
 	"cmp"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"io"
 	"io/fs"
 	"log"
+	"maps"
 	"math"
 	"net/http"
 	"net/url"
@@ -20,10 +22,10 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dnswlt/kontoo/pkg/resources"
-	"golang.org/x/text/encoding/charmap"
 )
 
 // JSON API for server requests and responses.
@@ -31,6 +33,9 @@ type UpsertLedgerEntryRequest struct {
 	// Optional. If set, it is an update request, otherwise an add.
 	UpdateExisting bool         `json:"updateExisting"`
 	Entry          *LedgerEntry `json:"entry"`
+	// Set by the client to add the entry even if it looks suspicious
+	// (after a previous request returned StatusConfirmationRequired).
+	Confirmed bool `json:"confirmed"`
 }
 type UpsertLedgerEntryResponse struct {
 	Status      StatusCode `json:"status"`
@@ -41,6 +46,92 @@ type DeleteLedgerEntryRequest struct {
 	// We use a pointer to detect if the field was explicitly set.
 	SequenceNum *int64 `json:"sequenceNum"`
 }
+type PinLedgerEntryRequest struct {
+	SequenceNum int64 `json:"sequenceNum"`
+	Pinned      bool  `json:"pinned"`
+}
+type PinLedgerEntryResponse struct {
+	Status      StatusCode `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	SequenceNum int64      `json:"sequenceNum"`
+}
+
+// sitePage is a page of the UI that can be linked from the navigation bar
+// and found by site search.
+type sitePage struct {
+	key      string // Key in the "Nav" template context map and in LedgerSettings.HiddenPages.
+	title    string
+	path     string
+	feature  string // Feature that must be enabled to show the page. Empty if always shown.
+	inNav    bool   // Whether the page is shown in the navigation bar.
+	keywords []string
+}
+
+// sitePages lists all pages, in navigation bar order. Pages can be hidden
+// via LedgerSettings.HiddenPages or by disabling their feature.
+var sitePages = []sitePage{
+	{"ledger", "Ledger", "/kontoo/ledger", "", true, []string{"entries", "transactions"}},
+	{"positions", "Positions", "/kontoo/positions", "", true, []string{"holdings", "portfolio"}},
+	{"equityPositions", "Equity positions", "/kontoo/positions/equity", "", false, []string{"stocks", "shares", "profit", "loss"}},
+	{"maturingPositions", "Maturing positions", "/kontoo/positions/maturing", "", false, []string{"bonds", "maturity", "interest", "irr"}},
+	{"addEntry", "Add entry", "/kontoo/entries/new", "", true, []string{"new", "transaction"}},
+	{"addAsset", "Add asset", "/kontoo/assets/new", "", true, []string{"new"}},
+	{"uploadCSV", "Upload CSV", "/kontoo/csv/upload", "", true, []string{"import", "prices"}},
+	{"quotes", "Quotes", "/kontoo/quotes", "", true, []string{"prices", "exchange", "rates", "symbols"}},
+	{"custodians", "Custodians", "/kontoo/custodians", FeatureCustodians, true, []string{"banks", "brokers", "fees", "depots"}},
+	{"risk", "Risk", "/kontoo/risk", FeatureRisk, true, []string{"scenarios", "stress"}},
+	{"gaps", "Gaps", "/kontoo/reports/gaps", "", true, []string{"missing", "prices"}},
+	{"stats", "Stats", "/kontoo/stats", "", true, []string{"statistics", "summary"}},
+	{"trash", "Trash", "/kontoo/trash", "", true, []string{"deleted", "restore"}},
+	{"shiftDates", "Shift dates", "/kontoo/entries/shift", "", false, []string{"value", "dates", "undo"}},
+	{"calc", "Calc", "/kontoo/calc", "", true, []string{"calculator", "irr"}},
+}
+
+// pageVisible returns true if page p is neither hidden by the ledger's settings
+// nor disabled by its feature.
+func (s *Server) pageVisible(p *sitePage) bool {
+	store := s.Store()
+	if slices.Contains(store.Settings().HiddenPages, p.key) {
+		return false
+	}
+	return p.feature == "" || store.FeatureEnabled(p.feature)
+}
+
+// Bookmark is a link to a pinned ledger entry, shown in the navigation bar.
+type Bookmark struct {
+	Label string
+	URL   string
+}
+
+type SearchResponse struct {
+	Status StatusCode   `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Hits   []*SearchHit `json:"hits"`
+}
+
+type TrashEntryRequest struct {
+	// Required unless All is set.
+	SequenceNum *int64 `json:"sequenceNum"`
+	// Only for purge: purge all trashed entries.
+	All bool `json:"all"`
+}
+type TrashEntryResponse struct {
+	Status    StatusCode `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	NumPurged int        `json:"numPurged,omitempty"`
+}
+
+type ShiftDatesRequest struct {
+	// Ledger query selecting the entries to shift. Must not be empty.
+	Query string `json:"query"`
+	Days  int    `json:"days"`
+}
+type ShiftDatesResponse struct {
+	Status     StatusCode `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	NumShifted int        `json:"numShifted"`
+}
+
 type DeleteLedgerEntryResponse struct {
 	Status      StatusCode `json:"status"`
 	Error       string     `json:"error,omitempty"`
@@ -56,17 +147,50 @@ type UpsertAssetResponse struct {
 	AssetID string     `json:"assetId,omitempty"`
 }
 
+type UpsertCustodianRequest struct {
+	CustodianID string     `json:"custodianId,omitempty"`
+	Custodian   *Custodian `json:"custodian"`
+}
+type UpsertCustodianResponse struct {
+	Status      StatusCode `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CustodianID string     `json:"custodianId,omitempty"`
+}
+type DeleteCustodianRequest struct {
+	CustodianID string `json:"custodianId"`
+}
+type DeleteCustodianResponse struct {
+	Status StatusCode `json:"status"`
+	Error  string     `json:"error,omitempty"`
+}
+
+type CheckSymbolsResponse struct {
+	Status     StatusCode      `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	NumChecked int             `json:"numChecked"`
+	Broken     []*SymbolStatus `json:"broken,omitempty"`
+}
+
 type CsvUploadResponse struct {
 	Status     StatusCode `json:"status"`
 	Error      string     `json:"error,omitempty"`
 	NumEntries int        `json:"numEntries"`
 	InnerHTML  string     `json:"innerHTML"`
+	// The settings used to read the file, and whether they were
+	// remembered from a previous upload of a file in the same format.
+	ImportProfile *ImportProfile `json:"importProfile,omitempty"`
+	KnownFormat   bool           `json:"knownFormat"`
 }
 
 type AddQuoteItem struct {
 	AssetID     string `json:"assetID"`
 	Date        Date   `json:"date"`
 	PriceMicros Micros `json:"priceMicros"`
+	// Currency of the price, if known. Prices in a currency other than the
+	// asset's currency are rejected, unless Convert is true, in which case
+	// they are converted using the ledger's exchange rates at Date.
+	Currency Currency `json:"currency,omitempty"`
+	Convert  bool     `json:"convert,omitempty"`
 }
 type AddExchangeRateItem struct {
 	BaseCurrency  Currency `json:"baseCurrency"`
@@ -77,6 +201,8 @@ type AddExchangeRateItem struct {
 type AddQuotesRequest struct {
 	Quotes        []*AddQuoteItem        `json:"quotes"`
 	ExchangeRates []*AddExchangeRateItem `json:"exchangeRates"`
+	// Signature of the uploaded CSV file the quotes stem from, if any.
+	ImportSignature string `json:"importSignature,omitempty"`
 }
 type AddQuotesResponse struct {
 	Status        StatusCode `json:"status"`
@@ -104,6 +230,10 @@ type PositionTimelineResponse struct {
 	Status    StatusCode          `json:"status"`
 	Error     string              `json:"error,omitempty"`
 	Timelines []*PositionTimeline `json:"timelines,omitempty"`
+	// Start and end of the period actually used. For "Max", the start
+	// is the earliest timestamp of any of the timelines.
+	StartTimestamp int64 `json:"startTimestamp,omitempty"`
+	EndTimestamp   int64 `json:"endTimestamp,omitempty"`
 }
 type PositionsMaturitiesRequest struct {
 	EndTimestamp int64 `json:"endTimestamp"`
@@ -133,6 +263,52 @@ type EquityChartResponse struct {
 	ValueMicros []int64    `json:"valueMicros"`
 }
 
+type RiskChartRequest struct {
+	EndTimestamp int64 `json:"endTimestamp"`
+}
+type RiskChartResponse struct {
+	Status     StatusCode `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Currency   string     `json:"currency"`
+	Scenarios  []string   `json:"scenarios"`
+	Categories []string   `json:"categories"`
+	// ImpactMicros[i][j] is the impact of scenario i on category j.
+	ImpactMicros [][]int64 `json:"impactMicros"`
+}
+
+type IncomeChartRequest struct {
+	EndTimestamp int64 `json:"endTimestamp"`
+	// Number of years to return, including the year of EndTimestamp. Defaults to 3.
+	Years int `json:"years"`
+}
+type IncomeChartResponse struct {
+	Status   StatusCode `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	Currency string     `json:"currency"`
+	Years    []int      `json:"years"`
+	Months   []string   `json:"months"`
+	// InterestMicros[i][m] is the interest income in Years[i], Months[m].
+	// Same for DividendMicros and TotalMicros.
+	InterestMicros [][]int64 `json:"interestMicros"`
+	DividendMicros [][]int64 `json:"dividendMicros"`
+	TotalMicros    [][]int64 `json:"totalMicros"`
+}
+
+type AverageInterestRequest struct {
+	EndTimestamp int64  `json:"endTimestamp"`
+	Period       string `json:"period"`
+}
+type AverageInterestResponse struct {
+	Status     StatusCode `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Currency   string     `json:"currency"`
+	Timestamps []int64    `json:"timestamps"`
+	// Value-weighted average interest rate of fixed-income and cash positions.
+	InterestMicros []int64 `json:"interestMicros"`
+	// Total value of the positions the average is based on.
+	ValueMicros []int64 `json:"valueMicros"`
+}
+
 type LedgerAssetInfoRequest struct {
 	AssetID string `json:"assetId"`
 	Date    *Date  `json:"date"` // Optional
@@ -163,9 +339,13 @@ type CalculateIRRResponse struct {
 type StatusCode string
 
 const (
-	StatusOK              StatusCode = "OK"
-	StatusPartialSuccess  StatusCode = "PARTIAL_SUCCESS"
-	StatusInvalidArgument StatusCode = "INVALID_ARGUMENT"
+	StatusOK                 StatusCode = "OK"
+	StatusPartialSuccess     StatusCode = "PARTIAL_SUCCESS"
+	StatusInvalidArgument    StatusCode = "INVALID_ARGUMENT"
+	StatusFailedPrecondition StatusCode = "FAILED_PRECONDITION"
+	StatusUnavailable        StatusCode = "UNAVAILABLE"
+	// The request was not executed because it needs to be confirmed by the user.
+	StatusConfirmationRequired StatusCode = "CONFIRMATION_REQUIRED"
 )
 
 // END JSON API
@@ -191,13 +371,76 @@ type Server struct {
 	// Use embedded resources if empty.
 	baseDir   string
 	templates *template.Template
+	// Guards store and its contents. Handlers that only read the store hold it
+	// for reading, handlers and admin commands that modify it hold it for writing.
+	mu        sync.RWMutex
 	store     *Store
 	debugMode bool
-	// Stock quote service
-	yFinance *YFinance
+	// Stock quote service. Nil if quotes are not available.
+	quoteProvider QuoteProvider
+	// In offline mode, the server makes no outbound network requests.
+	offline bool
+	// Checks quote service symbols. Nil if quotes are not available.
+	symbolChecker *SymbolChecker
+	// Interval at which symbols are checked in the background. 0 disables background checks.
+	symbolCheckInterval time.Duration
+	// Trashed ledger entries are purged after this period. 0 keeps them forever.
+	trashRetention time.Duration
+	// Path of the unix socket for the admin interface. Empty if disabled.
+	adminSocket string
+	// Directory for ledger backups. Defaults to the ledger's directory.
+	backupDir string
+}
+
+// Default period after which trashed ledger entries get purged.
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// Interval at which expired trash entries are purged in the background.
+const trashPurgeInterval = time.Hour
+
+// ServerOption configures optional aspects of a Server.
+type ServerOption func(*Server)
+
+// WithSymbolCheckInterval makes the server check periodically that
+// all quote service symbols still resolve at the quote provider.
+func WithSymbolCheckInterval(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.symbolCheckInterval = d
+	}
+}
+
+// WithTrashRetention sets the period after which deleted ledger entries
+// are purged from the trash. 0 keeps them forever.
+func WithTrashRetention(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.trashRetention = d
+	}
+}
+
+// WithQuoteProvider makes the server use p instead of Y! Finance for quotes.
+func WithQuoteProvider(p QuoteProvider) ServerOption {
+	return func(s *Server) {
+		s.quoteProvider = p
+	}
+}
+
+// WithFakeQuotes makes the server serve fake quotes based on the
+// latest prices and exchange rates in the ledger. Useful for offline development.
+func WithFakeQuotes() ServerOption {
+	return func(s *Server) {
+		s.quoteProvider = NewFakeQuoteProviderFromStore(s.store)
+	}
+}
+
+// WithOffline disables all outbound network requests, in particular to Y! Finance.
+// Prices and exchange rates must then be entered manually.
+func WithOffline() ServerOption {
+	return func(s *Server) {
+		s.offline = true
+	}
 }
 
-func NewServer(addr, ledgerPath, baseDir string) (*Server, error) {
+func NewServer(addr, ledgerPath, baseDir string, opts ...ServerOption) (*Server, error) {
 	if baseDir != "" {
 		expectedFiles := []string{
 			"css/style.css",
@@ -213,17 +456,26 @@ func NewServer(addr, ledgerPath, baseDir string) (*Server, error) {
 	if err != nil {
 		return nil, fmt.Errorf("cannot load store: %w", err)
 	}
-	yf, err := NewYFinance()
-	if err != nil {
-		log.Printf("Error creating YFinance. Stock quotes will not be available. Error: %v", err)
-		yf = nil // Should be nil anyway, but better be safe
-	}
 	s := &Server{
-		addr:       addr,
-		ledgerPath: ledgerPath,
-		baseDir:    baseDir,
-		store:      store,
-		yFinance:   yf,
+		addr:           addr,
+		ledgerPath:     ledgerPath,
+		baseDir:        baseDir,
+		store:          store,
+		trashRetention: defaultTrashRetention,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.quoteProvider == nil && !s.offline {
+		yf, err := NewYFinance()
+		if err != nil {
+			log.Printf("Error creating YFinance. Stock quotes will not be available. Error: %v", err)
+		} else {
+			s.quoteProvider = yf
+		}
+	}
+	if s.quoteProvider != nil {
+		s.symbolChecker = NewSymbolChecker(s.quoteProvider)
 	}
 	if err := s.reloadTemplates(); err != nil {
 		return nil, err
@@ -231,11 +483,17 @@ func NewServer(addr, ledgerPath, baseDir string) (*Server, error) {
 	return s, nil
 }
 
+// Store returns the server's store. Callers must hold s.mu;
+// HTTP handlers do so via readLocked or writeLocked.
 func (s *Server) Store() *Store {
 	return s.store
 }
 
+// ReloadStore replaces the server's store by the ledger read from disk.
 func (s *Server) ReloadStore() error {
+	// Hold the lock while loading, so we never read a partially saved ledger.
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	store, err := LoadStore(s.ledgerPath)
 	if err != nil {
 		return err
@@ -246,8 +504,8 @@ func (s *Server) ReloadStore() error {
 
 func (s *Server) DebugMode(enabled bool) {
 	s.debugMode = enabled
-	if s.yFinance != nil {
-		s.yFinance.EnableTracing(enabled)
+	if yf, ok := s.quoteProvider.(*YFinance); ok {
+		yf.EnableTracing(enabled)
 	}
 }
 
@@ -286,6 +544,9 @@ type PositionTableRow struct {
 	// Notes about the position to be displayed to the user
 	// (e.g. about old data being shown).
 	Notes []string
+	// Category under which the position is reported (see Store.ReportingCategory).
+	// If unset, AssetCategory falls back to the asset type's category.
+	ReportingCategory AssetCategory
 	// Maximum age of the data on which the Value and ValueBaseCurrency
 	// are calculated. Used to display warnings in the UI if the age is
 	// above a threshold.
@@ -329,6 +590,9 @@ func (r *PositionTableRow) ProfitLoss1YRatio() Micros {
 }
 
 func (r *PositionTableRow) AssetCategory() AssetCategory {
+	if r.ReportingCategory != UnspecfiedAssetCategory {
+		return r.ReportingCategory
+	}
 	return r.AssetType.category()
 }
 
@@ -426,7 +690,7 @@ func equityPositionTableRows(s *Store, date Date) []*PositionTableRow {
 func positionTableRows(s *Store, date Date) []*PositionTableRow {
 	positions := s.AssetPositionsAt(date)
 	slices.SortFunc(positions, func(a, b *AssetPosition) int {
-		c := int(a.Asset.Category()) - int(b.Asset.Category())
+		c := int(s.ReportingCategory(a.Asset, date)) - int(s.ReportingCategory(b.Asset, date))
 		if c != 0 {
 			return c
 		}
@@ -443,18 +707,23 @@ func positionTableRows(s *Store, date Date) []*PositionTableRow {
 		if !p.LastUpdated.IsZero() {
 			notes = append(notes, fmt.Sprintf("Last updated: %s", p.LastUpdated))
 		}
+		cat := s.ReportingCategory(a, date)
+		if cat != a.Category() {
+			notes = append(notes, fmt.Sprintf("Matures on %s: reported as %s", a.MaturityDate, cat))
+		}
 		// Ignoring the error is fine: we interpret a 0 exchange rate as a missing value
 		// and we can't do much more here if the rate is missing anyway.
 		rate, _, _ := s.ExchangeRateAt(a.Currency, date)
 		res[i] = &PositionTableRow{
-			AssetID:      a.ID(),
-			AssetName:    a.Name,
-			AssetType:    a.Type,
-			Currency:     a.Currency,
-			ExchangeRate: rate,
-			Value:        p.MarketValue(),
-			Notes:        notes,
-			DataAge:      date.Sub(p.LastUpdated.Time),
+			AssetID:           a.ID(),
+			AssetName:         a.Name,
+			AssetType:         a.Type,
+			Currency:          a.Currency,
+			ExchangeRate:      rate,
+			Value:             p.MarketValue(),
+			Notes:             notes,
+			DataAge:           date.Sub(p.LastUpdated.Time),
+			ReportingCategory: cat,
 		}
 	}
 	return res
@@ -521,6 +790,7 @@ func (s *Server) addCommonCtx(r *http.Request, ctx map[string]any) map[string]an
 	ctx["Today"] = time.Now().Format("2006-01-02")
 	ctx["Now"] = time.Now().Format("2006-01-02 15:04:05")
 	ctx["BaseCurrency"] = s.Store().BaseCurrency()
+	ctx["Offline"] = s.offline
 	ctx["ThisPage"] = r.URL.String()
 	ctxQ := make(url.Values)
 	// Inherit contextual query params from the incoming request.
@@ -529,21 +799,139 @@ func (s *Server) addCommonCtx(r *http.Request, ctx map[string]any) map[string]an
 		ctx["Date"] = date
 		ctxQ.Set("date", date)
 	}
-	ctx["Nav"] = map[string]string{
-		// Default filter for ledger view: no prices and exchange rates.
-		"ledger":        newURL("/kontoo/ledger", addP(ctxQ, "q", "$main")).String(),
-		"positions":     newURL("/kontoo/positions", ctxQ).String(),
-		"addEntry":      newURL("/kontoo/entries/new", ctxQ).String(),
+	nav := map[string]string{
 		"updateBalance": newURL("/kontoo/entries/new", addP(ctxQ, "prefill", "balance")).String(),
-		"addAsset":      newURL("/kontoo/assets/new", ctxQ).String(),
 		"editAsset":     newURL("/kontoo/assets/edit/{assetID}", ctxQ).String(),
-		"uploadCSV":     newURL("/kontoo/csv/upload", ctxQ).String(),
-		"quotes":        newURL("/kontoo/quotes", ctxQ).String(),
-		"calc":          newURL("/kontoo/calc", ctxQ).String(),
 	}
+	for _, p := range sitePages {
+		nav[p.key] = newURL(p.path, ctxQ).String()
+	}
+	// Default filter for ledger view: no prices and exchange rates.
+	nav["ledger"] = newURL("/kontoo/ledger", addP(ctxQ, "q", "$main")).String()
+	ctx["Nav"] = nav
+	var navItems []NavLink
+	for i := range sitePages {
+		p := &sitePages[i]
+		if p.inNav && s.pageVisible(p) {
+			navItems = append(navItems, NavLink{Title: p.title, URL: nav[p.key]})
+		}
+	}
+	for _, l := range s.Store().Settings().CustomLinks {
+		navItems = append(navItems, *l)
+	}
+	ctx["NavItems"] = navItems
+	features := make(map[string]bool)
+	for f := range defaultFeatures {
+		features[f] = s.Store().FeatureEnabled(f)
+	}
+	ctx["Features"] = features
+	var bookmarks []Bookmark
+	for _, b := range s.Store().Bookmarks() {
+		label := b.Label()
+		if label == "" {
+			label = b.EntryType().String()
+		}
+		bookmarks = append(bookmarks, Bookmark{
+			Label: fmt.Sprintf("%s %s", b.ValueDate(), label),
+			URL:   newURL("/kontoo/ledger", addP(ctxQ, "q", fmt.Sprintf("num:%d", b.SequenceNum()))).String(),
+		})
+	}
+	ctx["Bookmarks"] = bookmarks
 	return ctx
 }
 
+// LedgerEntryRowGroup is a group of ledger rows that belong to the same asset
+// (or to the same currency pair, for exchange rates).
+type LedgerEntryRowGroup struct {
+	Key   string // Asset ID or currency pair.
+	Label string
+	Rows  []*LedgerEntryRow
+	// Collapsed groups only show their subtotal row.
+	Collapsed bool
+	// URL of the ledger page with the group's collapsed state toggled.
+	ToggleURL string
+}
+
+// Latest returns the chronologically latest row of the group, which
+// holds the asset position's accumulated values.
+func (g *LedgerEntryRowGroup) Latest() *LedgerEntryRow {
+	var latest *LedgerEntryRow
+	for _, r := range g.Rows {
+		if latest == nil || cmpLedgerEntry(latest.E, r.E) < 0 {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// TotalValue returns the sum of the values of the group's flow entries
+// (purchases, credits, payments, etc.). Balances, holdings, and prices
+// are states, not flows, and are ignored.
+func (g *LedgerEntryRowGroup) TotalValue() Micros {
+	var sum Micros
+	for _, r := range g.Rows {
+		if r.E.Type.IsFlow() {
+			sum += r.Value()
+		}
+	}
+	return sum
+}
+
+// TotalCost returns the sum of the costs of the group's flow entries.
+func (g *LedgerEntryRowGroup) TotalCost() Micros {
+	var sum Micros
+	for _, r := range g.Rows {
+		if r.E.Type.IsFlow() {
+			sum += r.Cost()
+		}
+	}
+	return sum
+}
+
+func ledgerEntryRowGroupKey(r *LedgerEntryRow) string {
+	if r.HasAsset() {
+		return r.AssetID()
+	}
+	return r.Label()
+}
+
+// ledgerEntryRowGroups groups rows by asset. Groups are ordered by their first
+// row in rows, so the query's ordering is retained. The collapsed state of
+// groups is read from the "collapsed" query parameter(s) of u.
+func ledgerEntryRowGroups(rows []*LedgerEntryRow, u *url.URL) []*LedgerEntryRowGroup {
+	q := u.Query()
+	q.Del("snippet")
+	collapsed := q["collapsed"]
+	var groups []*LedgerEntryRowGroup
+	byKey := make(map[string]*LedgerEntryRowGroup)
+	for _, r := range rows {
+		key := ledgerEntryRowGroupKey(r)
+		g, ok := byKey[key]
+		if !ok {
+			g = &LedgerEntryRowGroup{
+				Key:       key,
+				Label:     r.Label(),
+				Collapsed: slices.Contains(collapsed, key),
+			}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.Rows = append(g.Rows, r)
+	}
+	for _, g := range groups {
+		var toggled []string
+		if g.Collapsed {
+			toggled = slices.DeleteFunc(slices.Clone(collapsed), func(k string) bool { return k == g.Key })
+		} else {
+			toggled = append(slices.Clone(collapsed), g.Key)
+		}
+		tq := maps.Clone(q)
+		tq["collapsed"] = toggled
+		g.ToggleURL = newURL(u.Path, tq).String()
+	}
+	return groups
+}
+
 func (s *Server) renderLedgerTemplate(w io.Writer, r *http.Request, query *Query, snippet bool) error {
 	rows := s.Store().LedgerEntryRows(query)
 	tmpl := "ledger.html"
@@ -554,6 +942,20 @@ func (s *Server) renderLedgerTemplate(w io.Writer, r *http.Request, query *Query
 		"TableRows": rows,
 		"Query":     query.raw,
 	})
+	if r.URL.Query().Get("group") == "asset" {
+		groups := ledgerEntryRowGroups(rows, r.URL)
+		// Links to collapse and expand all groups.
+		q := r.URL.Query()
+		q.Del("snippet")
+		q.Del("collapsed")
+		ctx["ExpandAllURL"] = newURL(r.URL.Path, q).String()
+		for _, g := range groups {
+			q.Add("collapsed", g.Key)
+		}
+		ctx["CollapseAllURL"] = newURL(r.URL.Path, q).String()
+		ctx["Groups"] = groups
+		ctx["Grouped"] = true
+	}
 	return s.templates.ExecuteTemplate(w, tmpl, ctx)
 }
 
@@ -601,6 +1003,7 @@ func (s *Server) renderEntryTemplate(w io.Writer, r *http.Request, entry *Ledger
 		"BaseCurrency":    s.Store().BaseCurrency(),
 		"QuoteCurrencies": quoteCurrencies,
 		"EntryTypes":      EntryTypeValues()[1:],
+		"Depots":          s.Store().Depots(),
 		"Entry":           entry,
 	})
 	return s.templates.ExecuteTemplate(w, "entry.html", ctx)
@@ -621,6 +1024,7 @@ func (s *Server) renderAssetTemplate(w io.Writer, r *http.Request, asset *Asset)
 	ctx := s.addCommonCtx(r, map[string]any{
 		"AssetTypes":           assetTypes,
 		"InterestPaymentTypes": allInterestPaymentSchedules,
+		"Custodians":           s.Store().Custodians(),
 		"Asset":                asset,
 	})
 	return s.templates.ExecuteTemplate(w, "asset.html", ctx)
@@ -636,10 +1040,17 @@ func (s *Server) renderQuotesTemplate(w io.Writer, r *http.Request, date Date) e
 		Date         time.Time
 		LatestDate   Date
 		DataAge      time.Duration
+		// Set if the quote's currency differs from the asset's currency.
+		CurrencyMismatch bool
+		AssetCurrency    Currency
+		// ClosingPrice converted to AssetCurrency. Zero if no exchange rate is known.
+		ConvertedPrice Micros
 	}
-	if s.yFinance == nil {
+	if s.quoteProvider == nil {
 		// No quotes service, can't show quotes.
-		return s.templates.ExecuteTemplate(w, "quotes.html", s.addCommonCtx(r, map[string]any{}))
+		return s.templates.ExecuteTemplate(w, "quotes.html", s.addCommonCtx(r, map[string]any{
+			"Unavailable": true,
+		}))
 	}
 	assets := s.Store().FindAssetsForQuoteService("YF")
 	entries := make([]*QuoteEntry, 0, len(assets))
@@ -653,9 +1064,10 @@ func (s *Server) renderQuotesTemplate(w io.Writer, r *http.Request, date Date) e
 		}
 		// Request prices at 18:00 (EOD) of the requested date in the relevant time zone.
 		t := time.Date(date.Year(), date.Month(), date.Day(), 18, 0, 0, 0, loc)
-		h, err := s.yFinance.GetDailyQuote(symbol, t)
+		h, err := s.quoteProvider.GetDailyQuote(symbol, t)
 		if err != nil {
 			log.Printf("Failed to get price history: %v", err)
+			s.symbolChecker.RecordError(asset, symbol, err)
 			var connErr *url.Error
 			if errors.As(err, &connErr) {
 				errorMessage = err.Error()
@@ -664,23 +1076,29 @@ func (s *Server) renderQuotesTemplate(w io.Writer, r *http.Request, date Date) e
 			continue
 		}
 		_, priceDate, _ := s.Store().PriceAt(asset.ID(), ToDate(h.Timestamp))
-		entries = append(entries, &QuoteEntry{
-			AssetID:      asset.ID(),
-			AssetName:    asset.Name,
-			Symbol:       h.Symbol,
-			Currency:     h.Currency,
-			ClosingPrice: h.ClosingPrice,
-			Date:         h.Timestamp,
-			LatestDate:   priceDate,
-			DataAge:      h.Timestamp.Sub(priceDate.Time),
-		})
+		e := &QuoteEntry{
+			AssetID:       asset.ID(),
+			AssetName:     asset.Name,
+			Symbol:        h.Symbol,
+			Currency:      h.Currency,
+			ClosingPrice:  h.ClosingPrice,
+			Date:          h.Timestamp,
+			LatestDate:    priceDate,
+			DataAge:       h.Timestamp.Sub(priceDate.Time),
+			AssetCurrency: asset.Currency,
+		}
+		if h.Currency != "" && h.Currency != asset.Currency {
+			e.CurrencyMismatch = true
+			e.ConvertedPrice, _ = s.Store().ConvertAt(h.ClosingPrice, h.Currency, asset.Currency, ToDate(h.Timestamp))
+		}
+		entries = append(entries, e)
 	}
 	quoteCurrencies := s.Store().QuoteCurrencies()
 	exchangeRates := make([]*DailyExchangeRate, 0, len(quoteCurrencies))
 	if errorMessage == "" {
 		for _, qc := range quoteCurrencies {
 			// Use UTC here on purpose: exchange rates in Y! are Europe/London based anyway.
-			rate, err := s.yFinance.GetDailyExchangeRate(s.Store().BaseCurrency(), qc, date.Time)
+			rate, err := s.quoteProvider.GetDailyExchangeRate(s.Store().BaseCurrency(), qc, date.Time)
 			if err != nil {
 				log.Printf("Failed to get exchange rate: %v", err)
 				var connErr *url.Error
@@ -693,10 +1111,16 @@ func (s *Server) renderQuotesTemplate(w io.Writer, r *http.Request, date Date) e
 			exchangeRates = append(exchangeRates, rate)
 		}
 	}
+	var brokenSymbols []*SymbolStatus
+	if s.Store().FeatureEnabled(FeatureSymbolCheck) {
+		brokenSymbols = s.symbolChecker.Broken(assets)
+	}
 	ctx := s.addCommonCtx(r, map[string]any{
-		"Entries":       entries,
-		"ExchangeRates": exchangeRates,
-		"Error":         errorMessage,
+		"Entries":            entries,
+		"ExchangeRates":      exchangeRates,
+		"Error":              errorMessage,
+		"BrokenSymbols":      brokenSymbols,
+		"SymbolsLastChecked": s.symbolChecker.LastRun(),
 	})
 	return s.templates.ExecuteTemplate(w, "quotes.html", ctx)
 }
@@ -739,6 +1163,7 @@ func (s *Server) renderMaturingPositionsTemplate(w io.Writer, r *http.Request, d
 	if totalValue > 0 {
 		totalIRR = totalIRR.Div(totalValue)
 	}
+	avgInterest, _ := s.Store().AverageInterest(date)
 	ctx := s.addCommonCtx(r, map[string]any{
 		"TableRows": rows,
 		"ActiveChips": map[string]bool{
@@ -749,6 +1174,7 @@ func (s *Server) renderMaturingPositionsTemplate(w io.Writer, r *http.Request, d
 			"Value":                totalValue,
 			"EarningsAtMaturity":   totalEarnings,
 			"InternalRateOfReturn": totalIRR,
+			"AverageInterest":      avgInterest,
 		},
 		"MonthOptions": monthOptions(*r.URL, date, maxDate),
 		"YearOptions":  yearOptions(*r.URL, date, minDate, maxDate),
@@ -801,8 +1227,153 @@ func (s *Server) renderEquityPositionsTemplate(w io.Writer, r *http.Request, dat
 	return s.templates.ExecuteTemplate(w, "positions_equity.html", ctx)
 }
 
+func (s *Server) renderRiskTemplate(w io.Writer, r *http.Request, date Date) error {
+	ctx := s.addCommonCtx(r, map[string]any{
+		"Report": s.Store().StressTest(date, defaultStressScenarios),
+	})
+	return s.templates.ExecuteTemplate(w, "risk.html", ctx)
+}
+
+func (s *Server) renderGapsTemplate(w io.Writer, r *http.Request, date Date, minDays int) error {
+	priceGaps, rateGaps := s.Store().DataGaps(date, minDays)
+	ctx := s.addCommonCtx(r, map[string]any{
+		"MinDays":   minDays,
+		"PriceGaps": priceGaps,
+		"RateGaps":  rateGaps,
+	})
+	return s.templates.ExecuteTemplate(w, "gaps.html", ctx)
+}
+
+func (s *Server) renderStatsTemplate(w io.Writer, r *http.Request) error {
+	ctx := s.addCommonCtx(r, map[string]any{
+		"Stats":      s.Store().Stats(10),
+		"LedgerPath": s.ledgerPath,
+	})
+	return s.templates.ExecuteTemplate(w, "stats.html", ctx)
+}
+
+func (s *Server) renderCustodiansTemplate(w io.Writer, r *http.Request, date Date, edit *Custodian) error {
+	holdings := s.Store().CustodianHoldings(date)
+	var totalValue, totalFees Micros
+	for _, h := range holdings {
+		totalValue += h.ValueMicros
+		totalFees += h.EstimatedFeeMicros
+	}
+	if edit == nil {
+		edit = &Custodian{} // Ensure template can render empty values.
+	}
+	ctx := s.addCommonCtx(r, map[string]any{
+		"Holdings":   holdings,
+		"TotalValue": totalValue,
+		"TotalFees":  totalFees,
+		"Custodians": s.Store().Custodians(),
+		"Edit":       edit,
+	})
+	return s.templates.ExecuteTemplate(w, "custodians.html", ctx)
+}
+
+func (s *Server) renderTrashTemplate(w io.Writer, r *http.Request) error {
+	type Row struct {
+		*LedgerEntry
+		Deleted   time.Time
+		AssetName string
+		Expires   time.Time
+	}
+	var rows []*Row
+	now := time.Now()
+	for _, t := range s.Store().Trash() {
+		if s.trashRetention > 0 && t.Deleted.Add(s.trashRetention).Before(now) {
+			continue // Expired, will be purged soon.
+		}
+		row := &Row{
+			LedgerEntry: t.Entry,
+			Deleted:     t.Deleted,
+		}
+		if a := s.Store().assets[t.Entry.AssetID]; a != nil {
+			row.AssetName = a.Name
+		}
+		if s.trashRetention > 0 {
+			row.Expires = t.Deleted.Add(s.trashRetention)
+		}
+		rows = append(rows, row)
+	}
+	return s.templates.ExecuteTemplate(w, "trash.html", s.addCommonCtx(r, map[string]any{
+		"TableRows":     rows,
+		"RetentionDays": int(s.trashRetention.Hours() / 24),
+	}))
+}
+
+func (s *Server) renderShiftDatesTemplate(w io.Writer, r *http.Request, query *Query, days int) error {
+	type Row struct {
+		*LedgerEntryRow
+		OldDate Date
+		NewDate Date
+	}
+	var preview []*Row
+	var previewError string
+	if query.raw != "" && days != 0 {
+		rows := s.Store().LedgerEntryRows(query)
+		seqNums := make([]int64, len(rows))
+		for i, row := range rows {
+			seqNums[i] = row.SequenceNum()
+		}
+		shift, err := s.Store().PlanDateShift(seqNums, days)
+		if err != nil {
+			return err
+		}
+		for i, se := range shift.Entries {
+			preview = append(preview, &Row{
+				LedgerEntryRow: rows[i],
+				OldDate:        se.OldDate,
+				NewDate:        se.NewDate,
+			})
+		}
+		if err := s.Store().ValidateDateShift(shift); err != nil {
+			previewError = err.Error()
+		}
+	}
+	var lastShift []*Row
+	if shift := s.Store().LastDateShift(); shift != nil {
+		for _, se := range shift.Entries {
+			e := s.Store().FindEntryBySequenceNum(se.SequenceNum)
+			if e == nil {
+				continue
+			}
+			lastShift = append(lastShift, &Row{
+				LedgerEntryRow: &LedgerEntryRow{E: e, A: s.Store().assets[e.AssetID]},
+				OldDate:        se.OldDate,
+				NewDate:        se.NewDate,
+			})
+		}
+	}
+	return s.templates.ExecuteTemplate(w, "shift.html", s.addCommonCtx(r, map[string]any{
+		"Query":        query.raw,
+		"Days":         days,
+		"Preview":      preview,
+		"PreviewError": previewError,
+		"LastShift":    s.Store().LastDateShift(),
+		"LastRows":     lastShift,
+	}))
+}
+
 func (s *Server) renderUploadCsvTemplate(w io.Writer, r *http.Request) error {
-	return s.templates.ExecuteTemplate(w, "upload_csv.html", s.addCommonCtx(r, map[string]any{}))
+	type Column struct {
+		Field         string
+		DefaultHeader string
+	}
+	columns := make([]Column, len(DepotExportFields))
+	for i, f := range DepotExportFields {
+		columns[i].Field = f
+		for h, g := range DefaultDepotExportColumns {
+			if f == g {
+				columns[i].DefaultHeader = h
+			}
+		}
+	}
+	return s.templates.ExecuteTemplate(w, "upload_csv.html", s.addCommonCtx(r, map[string]any{
+		"Encodings": ImportEncodings,
+		"Columns":   columns,
+	}))
 }
 
 func (s *Server) renderCalcTemplate(w io.Writer, r *http.Request) error {
@@ -811,7 +1382,7 @@ func (s *Server) renderCalcTemplate(w io.Writer, r *http.Request) error {
 	}))
 }
 
-func (s *Server) renderSnipUploadCsvData(w io.Writer, items []*DepotExportItem, store *Store) error {
+func (s *Server) renderSnipUploadCsvData(w io.Writer, items []*DepotExportItem, profile *ImportProfile, store *Store) error {
 	type Row struct {
 		AssetID               string
 		AssetName             string
@@ -831,6 +1402,9 @@ func (s *Server) renderSnipUploadCsvData(w io.Writer, items []*DepotExportItem,
 			log.Fatalf("Program error: renderSnipUploadCsvData expects WKN to exist: %q", item.WKN)
 		}
 		p := s.Store().AssetPositionAt(asset.ID(), item.ValueDate)
+		// Preselect newer prices, but only for the assets chosen last time, if known.
+		preselect := p.PriceDate.Before(item.ValueDate.Time) &&
+			(len(profile.AssetIDs) == 0 || slices.Contains(profile.AssetIDs, asset.ID()))
 		rows = append(rows, &Row{
 			AssetID:               asset.ID(),
 			AssetName:             asset.Name,
@@ -839,13 +1413,14 @@ func (s *Server) renderSnipUploadCsvData(w io.Writer, items []*DepotExportItem,
 			Currency:              asset.Currency,
 			QuantityImportMicros:  item.QuantityMicros,
 			QuantityCurrentMicros: p.QuantityMicros,
-			Preselect:             p.PriceDate.Before(item.ValueDate.Time),
+			Preselect:             preselect,
 			PriceDate:             p.PriceDate,
 			DataAge:               item.ValueDate.Sub(p.PriceDate.Time),
 		})
 	}
 	return s.templates.ExecuteTemplate(w, "snip_upload_csv_data.html", map[string]any{
-		"Entries": rows,
+		"Entries":   rows,
+		"Signature": profile.Signature,
 	})
 }
 
@@ -869,6 +1444,10 @@ func (s *Server) handleLedger(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	snippet := q.Get("snippet") == "true"
+	if g := q.Get("group"); g != "" && g != "asset" {
+		http.Error(w, fmt.Sprintf("Invalid group= parameter: %q (must be \"asset\")", g), http.StatusBadRequest)
+		return
+	}
 	var buf bytes.Buffer
 	if err := s.renderLedgerTemplate(&buf, r, query, snippet); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to render template: %s", err), http.StatusInternalServerError)
@@ -1012,6 +1591,7 @@ func (s *Server) handlePositionsTimeline(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	var timelines []*PositionTimeline
+	minTimestamp := end.UnixMilli()
 	for _, assetId := range req.AssetIDs {
 		a, ok := s.Store().assets[assetId]
 		if !ok {
@@ -1027,18 +1607,47 @@ func (s *Server) handlePositionsTimeline(w http.ResponseWriter, r *http.Request)
 			t.QuantityMicros = append(t.QuantityMicros, int64(p.QuantityMicros))
 			t.ValueMicros = append(t.ValueMicros, int64(p.MarketValue()))
 		}
+		if len(t.Timestamps) > 0 {
+			minTimestamp = min(minTimestamp, t.Timestamps[0])
+		}
 		timelines = append(timelines, t)
 	}
 	if len(timelines) == 0 {
+		if wantsCSV(r) {
+			http.Error(w, fmt.Sprintf("No assets found for given %d IDs", len(req.AssetIDs)), http.StatusBadRequest)
+			return
+		}
 		s.jsonResponse(w, PositionTimelineResponse{
 			Status: StatusInvalidArgument,
 			Error:  fmt.Sprintf("No assets found for given %d IDs", len(req.AssetIDs)),
 		})
 		return
 	}
+	if wantsCSV(r) {
+		records := [][]string{{"AssetID", "AssetName", "Date", "Quantity", "Value"}}
+		for _, t := range timelines {
+			for i, ts := range t.Timestamps {
+				records = append(records, []string{
+					t.AssetID,
+					t.AssetName,
+					ToDate(time.UnixMilli(ts).In(time.UTC)).String(),
+					Micros(t.QuantityMicros[i]).String(),
+					Micros(t.ValueMicros[i]).String(),
+				})
+			}
+		}
+		s.csvResponse(w, "timeline.csv", records)
+		return
+	}
+	startTimestamp := start.UnixMilli()
+	if start.IsZero() {
+		startTimestamp = minTimestamp
+	}
 	s.jsonResponse(w, PositionTimelineResponse{
-		Status:    StatusOK,
-		Timelines: timelines,
+		Status:         StatusOK,
+		Timelines:      timelines,
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   end.UnixMilli(),
 	})
 }
 
@@ -1069,6 +1678,15 @@ func (s *Server) handleChartsEquity(w http.ResponseWriter, r *http.Request) {
 		assetNames = append(assetNames, r.AssetName)
 		valueMicros = append(valueMicros, int64(r.Value.Div(r.ExchangeRate)))
 	}
+	if wantsCSV(r) {
+		currency := string(s.Store().BaseCurrency())
+		records := [][]string{{"AssetName", "Currency", "Value"}}
+		for i, name := range assetNames {
+			records = append(records, []string{name, currency, Micros(valueMicros[i]).String()})
+		}
+		s.csvResponse(w, "equity.csv", records)
+		return
+	}
 	s.jsonResponse(w, EquityChartResponse{
 		Status:      StatusOK,
 		Currency:    string(s.Store().BaseCurrency()),
@@ -1077,6 +1695,116 @@ func (s *Server) handleChartsEquity(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (s *Server) handleChartsIncome(w http.ResponseWriter, r *http.Request) {
+	var req IncomeChartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	numYears := req.Years
+	if numYears == 0 {
+		numYears = 3
+	}
+	if numYears < 0 || numYears > 100 {
+		msg := fmt.Sprintf("years must be between 1 and 100, was %d", numYears)
+		if wantsCSV(r) {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		s.jsonResponse(w, IncomeChartResponse{
+			Status: StatusInvalidArgument,
+			Error:  msg,
+		})
+		return
+	}
+	date := ToDate(time.UnixMilli(req.EndTimestamp).In(time.UTC))
+	inc := s.Store().MonthlyIncome(date.Year(), numYears)
+	months := make([]string, 12)
+	for m := range months {
+		months[m] = time.Month(m + 1).String()[:3]
+	}
+	currency := string(s.Store().BaseCurrency())
+	if wantsCSV(r) {
+		records := [][]string{{"Year", "Month", "Currency", "Interest", "Dividends", "Total"}}
+		for i, y := range inc.Years {
+			for m := range months {
+				records = append(records, []string{
+					strconv.Itoa(y), strconv.Itoa(m + 1), currency,
+					inc.InterestMicros[i][m].String(), inc.DividendMicros[i][m].String(), inc.Total(i, m).String(),
+				})
+			}
+		}
+		s.csvResponse(w, "income.csv", records)
+		return
+	}
+	resp := IncomeChartResponse{
+		Status:         StatusOK,
+		Currency:       currency,
+		Years:          inc.Years,
+		Months:         months,
+		InterestMicros: make([][]int64, len(inc.Years)),
+		DividendMicros: make([][]int64, len(inc.Years)),
+		TotalMicros:    make([][]int64, len(inc.Years)),
+	}
+	for i := range inc.Years {
+		resp.InterestMicros[i] = make([]int64, 12)
+		resp.DividendMicros[i] = make([]int64, 12)
+		resp.TotalMicros[i] = make([]int64, 12)
+		for m := range 12 {
+			resp.InterestMicros[i][m] = int64(inc.InterestMicros[i][m])
+			resp.DividendMicros[i][m] = int64(inc.DividendMicros[i][m])
+			resp.TotalMicros[i][m] = int64(inc.Total(i, m))
+		}
+	}
+	s.jsonResponse(w, resp)
+}
+
+func (s *Server) handlePositionsInterest(w http.ResponseWriter, r *http.Request) {
+	var req AverageInterestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	end := ToDate(time.UnixMilli(req.EndTimestamp).In(time.UTC))
+	start, err := parsePeriod(end, req.Period)
+	if err != nil {
+		if wantsCSV(r) {
+			http.Error(w, "invalid period: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.jsonResponse(w, AverageInterestResponse{
+			Status: StatusInvalidArgument,
+			Error:  "invalid period: " + err.Error(),
+		})
+		return
+	}
+	if start.IsZero() {
+		start, _ = s.Store().ValueDateRange()
+	}
+	points := s.Store().AverageInterestHistory(start, end)
+	currency := string(s.Store().BaseCurrency())
+	if wantsCSV(r) {
+		records := [][]string{{"Date", "Currency", "Value", "Interest"}}
+		for _, p := range points {
+			records = append(records, []string{
+				p.Date.String(), currency, p.ValueMicros.String(), p.RateMicros.String(),
+			})
+		}
+		s.csvResponse(w, "interest.csv", records)
+		return
+	}
+	resp := AverageInterestResponse{
+		Status:   StatusOK,
+		Currency: currency,
+	}
+	for _, p := range points {
+		resp.Timestamps = append(resp.Timestamps, p.Date.UnixMilli())
+		resp.InterestMicros = append(resp.InterestMicros, int64(p.RateMicros))
+		resp.ValueMicros = append(resp.ValueMicros, int64(p.ValueMicros))
+	}
+	s.jsonResponse(w, resp)
+}
+
 func (s *Server) handlePositionsMaturities(w http.ResponseWriter, r *http.Request) {
 	var req PositionsMaturitiesRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1113,6 +1841,15 @@ func (s *Server) handlePositionsMaturities(w http.ResponseWriter, r *http.Reques
 			bucketLabels[i] = fmt.Sprintf(">= %d", bounds[i])
 		}
 	}
+	if wantsCSV(r) {
+		currency := string(s.Store().BaseCurrency())
+		records := [][]string{{"YearsToMaturity", "Currency", "Value"}}
+		for i, label := range bucketLabels {
+			records = append(records, []string{label, currency, Micros(buckets[i]).String()})
+		}
+		s.csvResponse(w, "maturities.csv", records)
+		return
+	}
 	s.jsonResponse(w, PositionsMaturitiesResponse{
 		Status: StatusOK,
 		Maturities: &MaturitiesChartData{
@@ -1128,6 +1865,50 @@ func (s *Server) handlePositionsMaturities(w http.ResponseWriter, r *http.Reques
 	})
 }
 
+func (s *Server) handleChartsRisk(w http.ResponseWriter, r *http.Request) {
+	var req RiskChartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	date := ToDate(time.UnixMilli(req.EndTimestamp).In(time.UTC))
+	report := s.Store().StressTest(date, defaultStressScenarios)
+	scenarios := make([]string, len(report.Scenarios))
+	impacts := make([][]int64, len(report.Scenarios))
+	for i, sc := range report.Scenarios {
+		scenarios[i] = sc.Name
+		impacts[i] = make([]int64, len(report.Categories))
+		for j, m := range report.Impacts[i] {
+			impacts[i][j] = int64(m)
+		}
+	}
+	categories := make([]string, len(report.Categories))
+	for j, c := range report.Categories {
+		categories[j] = c.String()
+	}
+	if wantsCSV(r) {
+		// One row per category, one column per scenario.
+		records := [][]string{append([]string{"Category", "Currency"}, scenarios...)}
+		currency := string(s.Store().BaseCurrency())
+		for j, c := range categories {
+			row := []string{c, currency}
+			for i := range scenarios {
+				row = append(row, report.Impacts[i][j].String())
+			}
+			records = append(records, row)
+		}
+		s.csvResponse(w, "risk.csv", records)
+		return
+	}
+	s.jsonResponse(w, RiskChartResponse{
+		Status:       StatusOK,
+		Currency:     string(s.Store().BaseCurrency()),
+		Scenarios:    scenarios,
+		Categories:   categories,
+		ImpactMicros: impacts,
+	})
+}
+
 func (s *Server) handlePositions(w http.ResponseWriter, r *http.Request) {
 	date, ok := ensureDateParam(w, r)
 	if !ok {
@@ -1173,21 +1954,159 @@ func (s *Server) handlePositionsEquity(w http.ResponseWriter, r *http.Request) {
 	w.Write(buf.Bytes())
 }
 
-func (s *Server) handleCalc(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleRisk(w http.ResponseWriter, r *http.Request) {
+	date, ok := ensureDateParam(w, r)
+	if !ok {
+		return
+	}
 	var buf bytes.Buffer
-	err := s.renderCalcTemplate(&buf, r)
+	err := s.renderRiskTemplate(&buf, r, date)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to render template: %s", err), http.StatusInternalServerError)
+		return
 	}
 	w.Header().Set("Content-Type", "text/html")
 	w.Write(buf.Bytes())
 }
 
-func (s *Server) handleCalculate(w http.ResponseWriter, r *http.Request) {
-	var req CalculateIRRRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
-		return
+func (s *Server) handleReportsGaps(w http.ResponseWriter, r *http.Request) {
+	date, ok := ensureDateParam(w, r)
+	if !ok {
+		return
+	}
+	minDays := defaultMinGapDays
+	if d := r.URL.Query().Get("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n <= 0 {
+			http.Error(w, "days must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		minDays = n
+	}
+	var buf bytes.Buffer
+	err := s.renderGapsTemplate(&buf, r, date, minDays)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to render template: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	err := s.renderStatsTemplate(&buf, r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to render template: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) handleCustodians(w http.ResponseWriter, r *http.Request) {
+	date, ok := ensureDateParam(w, r)
+	if !ok {
+		return
+	}
+	var edit *Custodian
+	if id := r.URL.Query().Get("edit"); id != "" {
+		edit = s.Store().FindCustodian(id)
+		if edit == nil {
+			http.Error(w, "custodian not found", http.StatusNotFound)
+			return
+		}
+	}
+	var buf bytes.Buffer
+	if err := s.renderCustodiansTemplate(&buf, r, date, edit); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to render template: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Write(buf.Bytes())
+}
+
+// purgeExpiredTrash permanently deletes trashed entries whose retention period
+// is over and returns their number. It acquires the server's lock.
+func (s *Server) purgeExpiredTrash() (int, error) {
+	if s.trashRetention <= 0 {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.Store().PurgeTrash(time.Now().Add(-s.trashRetention))
+	if n > 0 {
+		log.Printf("Purged %d expired entries from the trash", n)
+		return n, s.Store().Save()
+	}
+	return 0, nil
+}
+
+// runTrashPurger purges expired trash entries every interval until done is closed.
+func (s *Server) runTrashPurger(interval time.Duration, done <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if _, err := s.purgeExpiredTrash(); err != nil {
+			log.Printf("Purging trash failed: %v", err)
+		}
+		select {
+		case <-t.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := s.renderTrashTemplate(&buf, r); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to render template: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) handleEntriesShift(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query, err := ParseQuery(q.Get("q"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+	var days int
+	if d := q.Get("days"); d != "" {
+		days, err = strconv.Atoi(d)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid days= parameter: %q", d), http.StatusBadRequest)
+			return
+		}
+	}
+	var buf bytes.Buffer
+	if err := s.renderShiftDatesTemplate(&buf, r, query, days); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to render template: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) handleCalc(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	err := s.renderCalcTemplate(&buf, r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to render template: %s", err), http.StatusInternalServerError)
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) handleCalculate(w http.ResponseWriter, r *http.Request) {
+	var req CalculateIRRRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
 	}
 	if req.PurchaseDate.IsZero() || req.MaturityDate.IsZero() {
 		s.jsonResponse(w, CalculateIRRResponse{
@@ -1240,6 +2159,25 @@ func (s *Server) handleCsvUpload(w http.ResponseWriter, r *http.Request) {
 	w.Write(buf.Bytes())
 }
 
+// wantsCSV returns true if the request asks for CSV instead of JSON output
+// via the format=csv query parameter. This is supported by all chart data endpoints.
+func wantsCSV(r *http.Request) bool {
+	return strings.EqualFold(r.URL.Query().Get("format"), "csv")
+}
+
+// csvResponse writes records as a CSV file. The first record should be the header.
+func (s *Server) csvResponse(w http.ResponseWriter, filename string, records [][]string) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	if err := cw.WriteAll(records); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to write CSV: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(buf.Bytes())
+}
+
 func (s *Server) jsonResponse(w http.ResponseWriter, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	err := json.NewEncoder(w).Encode(v)
@@ -1268,9 +2206,41 @@ func (s *Server) handleCsvPost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("invalid form data: %v", err), http.StatusBadRequest)
 		return
 	}
-	// Assume ISO 8859-15 encoding.
-	enc := charmap.ISO8859_15.NewDecoder().Reader(f)
-	items, err := ReadDepotExportCSV(enc)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot read file: %v", err), http.StatusBadRequest)
+		return
+	}
+	// Use the settings of previous uploads of files with the same header,
+	// unless the user explicitly provided them.
+	signature := CSVSignature(data)
+	profile := s.Store().ImportProfile(signature)
+	knownFormat := profile != nil
+	var saved ImportProfile
+	if profile == nil {
+		profile = &ImportProfile{
+			Signature: signature,
+			Encoding:  detectEncoding(data),
+		}
+	} else {
+		saved = *profile
+	}
+	if enc := r.FormValue("encoding"); enc != "" {
+		profile.Encoding = enc
+	}
+	if cols := r.FormValue("columns"); cols != "" {
+		profile.Columns = nil
+		if err := json.Unmarshal([]byte(cols), &profile.Columns); err != nil {
+			http.Error(w, fmt.Sprintf("invalid columns: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := validateImportProfile(profile); err != nil {
+		http.Error(w, fmt.Sprintf("invalid import settings: %v", err), http.StatusBadRequest)
+		return
+	}
+	items, err := profile.ReadCSV(data)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("error processing CSV: %v", err), http.StatusBadRequest)
 		return
@@ -1284,8 +2254,21 @@ func (s *Server) handleCsvPost(w http.ResponseWriter, r *http.Request) {
 		}
 		validItems = append(validItems, item)
 	}
+	// Only store the profile if it is new or its settings changed, so that
+	// previewing a file does not rewrite the ledger.
+	changed := !knownFormat || profile.Encoding != saved.Encoding || !maps.Equal(profile.Columns, saved.Columns)
+	if len(validItems) > 0 && changed {
+		if err := s.Store().PutImportProfile(profile); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to store import settings: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err := s.Store().Save(); err != nil {
+			http.Error(w, fmt.Sprintf("Error saving ledger: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
 	var buf bytes.Buffer
-	if err := s.renderSnipUploadCsvData(&buf, validItems, s.Store()); err != nil {
+	if err := s.renderSnipUploadCsvData(&buf, validItems, profile, s.Store()); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to render template: %s", err), http.StatusInternalServerError)
 		return
 	}
@@ -1301,10 +2284,12 @@ func (s *Server) handleCsvPost(w http.ResponseWriter, r *http.Request) {
 			strings.Join(skipped, ", "))
 	}
 	s.jsonResponse(w, CsvUploadResponse{
-		Status:     status,
-		Error:      errorText,
-		NumEntries: len(validItems),
-		InnerHTML:  buf.String(),
+		Status:        status,
+		Error:         errorText,
+		NumEntries:    len(validItems),
+		InnerHTML:     buf.String(),
+		ImportProfile: profile,
+		KnownFormat:   knownFormat,
 	})
 }
 
@@ -1318,6 +2303,15 @@ func (s *Server) handleEntriesPost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing entry in request", http.StatusBadRequest)
 		return
 	}
+	if !req.Confirmed {
+		if ws := s.Store().EntryWarnings(req.Entry); len(ws) > 0 {
+			s.jsonResponse(w, UpsertLedgerEntryResponse{
+				Status: StatusConfirmationRequired,
+				Error:  strings.Join(ws, "\n"),
+			})
+			return
+		}
+	}
 	if req.UpdateExisting {
 		// Update
 		if err := s.Store().Update(req.Entry); err != nil {
@@ -1394,6 +2388,9 @@ func (s *Server) handleEntriesDelete(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if s.trashRetention > 0 {
+		s.Store().PurgeTrash(time.Now().Add(-s.trashRetention))
+	}
 	if err := s.Store().Save(); err != nil {
 		http.Error(w, fmt.Sprintf("Error saving ledger: %v", err), http.StatusInternalServerError)
 		return
@@ -1404,6 +2401,186 @@ func (s *Server) handleEntriesDelete(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	if query == "" {
+		s.jsonResponse(w, SearchResponse{
+			Status: StatusInvalidArgument,
+			Error:  "q must not be empty",
+		})
+		return
+	}
+	limit := 20
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			s.jsonResponse(w, SearchResponse{
+				Status: StatusInvalidArgument,
+				Error:  fmt.Sprintf("invalid limit: %q", l),
+			})
+			return
+		}
+		limit = n
+	}
+	s.jsonResponse(w, SearchResponse{
+		Status: StatusOK,
+		Hits:   s.Search(query, limit),
+	})
+}
+
+func (s *Server) handleEntriesPin(w http.ResponseWriter, r *http.Request) {
+	var req PinLedgerEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Store().SetPinned(req.SequenceNum, req.Pinned); err != nil {
+		s.jsonResponse(w, PinLedgerEntryResponse{
+			Status: StatusInvalidArgument,
+			Error:  err.Error(),
+		})
+		return
+	}
+	if err := s.Store().Save(); err != nil {
+		http.Error(w, fmt.Sprintf("Error saving ledger: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.jsonResponse(w, PinLedgerEntryResponse{
+		Status:      StatusOK,
+		SequenceNum: req.SequenceNum,
+	})
+}
+
+func (s *Server) handleTrashRestore(w http.ResponseWriter, r *http.Request) {
+	var req TrashEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.SequenceNum == nil {
+		http.Error(w, "sequenceNum must be set", http.StatusBadRequest)
+		return
+	}
+	if err := s.Store().Restore(*req.SequenceNum); err != nil {
+		s.jsonResponse(w, TrashEntryResponse{
+			Status: StatusInvalidArgument,
+			Error:  err.Error(),
+		})
+		return
+	}
+	if err := s.Store().Save(); err != nil {
+		http.Error(w, fmt.Sprintf("Error saving ledger: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.jsonResponse(w, TrashEntryResponse{
+		Status: StatusOK,
+	})
+}
+
+func (s *Server) handleEntriesShiftPost(w http.ResponseWriter, r *http.Request) {
+	var req ShiftDatesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		s.jsonResponse(w, ShiftDatesResponse{
+			Status: StatusInvalidArgument,
+			Error:  "query must not be empty",
+		})
+		return
+	}
+	query, err := ParseQuery(req.Query)
+	if err != nil {
+		s.jsonResponse(w, ShiftDatesResponse{
+			Status: StatusInvalidArgument,
+			Error:  fmt.Sprintf("invalid query: %v", err),
+		})
+		return
+	}
+	rows := s.Store().LedgerEntryRows(query)
+	seqNums := make([]int64, len(rows))
+	for i, row := range rows {
+		seqNums[i] = row.SequenceNum()
+	}
+	if len(seqNums) == 0 {
+		s.jsonResponse(w, ShiftDatesResponse{
+			Status: StatusInvalidArgument,
+			Error:  "query matches no entries",
+		})
+		return
+	}
+	shift, err := s.Store().ShiftDates(seqNums, req.Days)
+	if err != nil {
+		s.jsonResponse(w, ShiftDatesResponse{
+			Status: StatusInvalidArgument,
+			Error:  err.Error(),
+		})
+		return
+	}
+	if err := s.Store().Save(); err != nil {
+		http.Error(w, fmt.Sprintf("Error saving ledger: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.jsonResponse(w, ShiftDatesResponse{
+		Status:     StatusOK,
+		NumShifted: len(shift.Entries),
+	})
+}
+
+func (s *Server) handleEntriesShiftUndo(w http.ResponseWriter, r *http.Request) {
+	shift, err := s.Store().UndoDateShift()
+	if err != nil {
+		s.jsonResponse(w, ShiftDatesResponse{
+			Status: StatusFailedPrecondition,
+			Error:  err.Error(),
+		})
+		return
+	}
+	if err := s.Store().Save(); err != nil {
+		http.Error(w, fmt.Sprintf("Error saving ledger: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.jsonResponse(w, ShiftDatesResponse{
+		Status:     StatusOK,
+		NumShifted: len(shift.Entries),
+	})
+}
+
+func (s *Server) handleTrashPurge(w http.ResponseWriter, r *http.Request) {
+	var req TrashEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	numPurged := 0
+	if req.All {
+		// Purge everything that was trashed until now.
+		numPurged = s.Store().PurgeTrash(time.Now().Add(time.Second))
+	} else if req.SequenceNum != nil {
+		if err := s.Store().Purge(*req.SequenceNum); err != nil {
+			s.jsonResponse(w, TrashEntryResponse{
+				Status: StatusInvalidArgument,
+				Error:  err.Error(),
+			})
+			return
+		}
+		numPurged = 1
+	} else {
+		http.Error(w, "sequenceNum or all must be set", http.StatusBadRequest)
+		return
+	}
+	if err := s.Store().Save(); err != nil {
+		http.Error(w, fmt.Sprintf("Error saving ledger: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.jsonResponse(w, TrashEntryResponse{
+		Status:    StatusOK,
+		NumPurged: numPurged,
+	})
+}
+
 func (s *Server) handleAssetsPost(w http.ResponseWriter, r *http.Request) {
 	var req UpsertAssetRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1416,8 +2593,8 @@ func (s *Server) handleAssetsPost(w http.ResponseWriter, r *http.Request) {
 	}
 	// Try to retrieve timezone for quote service symbol, if it is not already set.
 	if len(req.Asset.QuoteServiceSymbols) > 0 && req.Asset.QuoteServiceSymbols["YF"] != "" {
-		if s.yFinance != nil && req.Asset.ExchangeTimezone == "" {
-			qs, err := s.yFinance.FetchQuoteSummary(req.Asset.QuoteServiceSymbols["YF"])
+		if s.quoteProvider != nil && req.Asset.ExchangeTimezone == "" {
+			qs, err := s.quoteProvider.FetchQuoteSummary(req.Asset.QuoteServiceSymbols["YF"])
 			if err == nil {
 				log.Printf("Adding timezone %q to asset %q", qs.ExchangeTimezone(), req.Asset.Name)
 				req.Asset.ExchangeTimezone = qs.ExchangeTimezone()
@@ -1453,6 +2630,86 @@ func (s *Server) handleAssetsPost(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (s *Server) handleCustodiansPost(w http.ResponseWriter, r *http.Request) {
+	var req UpsertCustodianRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Custodian == nil {
+		http.Error(w, "missing custodian", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if req.CustodianID == "" {
+		err = s.Store().AddCustodian(req.Custodian)
+	} else {
+		err = s.Store().UpdateCustodian(req.CustodianID, req.Custodian)
+	}
+	if err != nil {
+		s.jsonResponse(w, UpsertCustodianResponse{
+			Status: StatusInvalidArgument,
+			Error:  err.Error(),
+		})
+		return
+	}
+	if err := s.Store().Save(); err != nil {
+		http.Error(w, fmt.Sprintf("Error saving ledger: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.jsonResponse(w, UpsertCustodianResponse{
+		Status:      StatusOK,
+		CustodianID: req.Custodian.ID,
+	})
+}
+
+func (s *Server) handleCustodiansDelete(w http.ResponseWriter, r *http.Request) {
+	var req DeleteCustodianRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Store().DeleteCustodian(req.CustodianID); err != nil {
+		s.jsonResponse(w, DeleteCustodianResponse{
+			Status: StatusInvalidArgument,
+			Error:  err.Error(),
+		})
+		return
+	}
+	if err := s.Store().Save(); err != nil {
+		http.Error(w, fmt.Sprintf("Error saving ledger: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.jsonResponse(w, DeleteCustodianResponse{
+		Status: StatusOK,
+	})
+}
+
+func (s *Server) handleQuotesSymbolsCheck(w http.ResponseWriter, r *http.Request) {
+	if s.symbolChecker == nil {
+		s.jsonResponse(w, CheckSymbolsResponse{
+			Status: StatusFailedPrecondition,
+			Error:  "quote service is not available",
+		})
+		return
+	}
+	// Don't hold the lock while talking to the quote service.
+	assets := s.symbolCheckAssets()
+	res, err := s.symbolChecker.Check(assets)
+	if err != nil {
+		s.jsonResponse(w, CheckSymbolsResponse{
+			Status: StatusUnavailable,
+			Error:  err.Error(),
+		})
+		return
+	}
+	s.jsonResponse(w, CheckSymbolsResponse{
+		Status:     StatusOK,
+		NumChecked: len(res),
+		Broken:     s.symbolChecker.Broken(assets),
+	})
+}
+
 func (s *Server) createLedgerEntries(r *AddQuotesRequest) ([]*LedgerEntry, error) {
 	result := make([]*LedgerEntry, 0, len(r.Quotes)+len(r.ExchangeRates))
 	for _, q := range r.Quotes {
@@ -1460,14 +2717,26 @@ func (s *Server) createLedgerEntries(r *AddQuotesRequest) ([]*LedgerEntry, error
 		if !ok {
 			return nil, fmt.Errorf("asset %q does not exist", q.AssetID)
 		}
+		price := q.PriceMicros
+		if q.Currency != "" && q.Currency != a.Currency {
+			if !q.Convert {
+				return nil, fmt.Errorf("price for %s is in %s, but the asset's currency is %s",
+					a.Name, q.Currency, a.Currency)
+			}
+			converted, ok := s.Store().ConvertAt(price, q.Currency, a.Currency, q.Date)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert price for %s from %s to %s: no exchange rate at %s",
+					a.Name, q.Currency, a.Currency, q.Date)
+			}
+			price = converted
+		}
 		result = append(result, &LedgerEntry{
 			Type:        AssetPrice,
 			ValueDate:   q.Date,
 			AssetID:     q.AssetID,
 			Currency:    a.Currency,
-			PriceMicros: q.PriceMicros,
+			PriceMicros: price,
 		})
-
 	}
 	for _, e := range r.ExchangeRates {
 		if !currencyRegexp.MatchString(string(e.BaseCurrency)) {
@@ -1498,19 +2767,31 @@ func (s *Server) handleQuotesPost(w http.ResponseWriter, r *http.Request) {
 	}
 	entries, err := s.createLedgerEntries(&req)
 	if err != nil {
-		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		s.jsonResponse(w, AddQuotesResponse{
+			Status: StatusInvalidArgument,
+			Error:  err.Error(),
+		})
 		return
 	}
 	imported := 0
 	var failures []string
+	var importedAssets []string
 	for _, e := range entries {
 		if err := s.Store().Add(e); err != nil {
 			failures = append(failures, fmt.Sprintf("Failed to add entry: %s", err))
 			continue
 		}
 		imported++
+		if e.AssetID != "" {
+			importedAssets = append(importedAssets, e.AssetID)
+		}
 	}
 	if imported > 0 {
+		if req.ImportSignature != "" {
+			if err := s.Store().RecordImportAssets(req.ImportSignature, importedAssets); err != nil {
+				log.Printf("Cannot remember imported assets: %v", err)
+			}
+		}
 		if err := s.Store().Save(); err != nil {
 			http.Error(w, fmt.Sprintf("Error saving ledger: %v", err), http.StatusInternalServerError)
 			return
@@ -1534,6 +2815,22 @@ func (s *Server) handleQuotesPost(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// featureHandler responds with 404 Not Found if feature is disabled for
+// the current ledger, and calls h otherwise. It acquires the server's lock
+// only to check the feature, so h must do its own locking.
+func (s *Server) featureHandler(feature string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		enabled := s.Store().FeatureEnabled(feature)
+		s.mu.RUnlock()
+		if !enabled {
+			http.Error(w, fmt.Sprintf("feature %q is disabled for this ledger", feature), http.StatusNotFound)
+			return
+		}
+		h(w, r)
+	}
+}
+
 func (s *Server) reloadHandler(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if s.debugMode {
@@ -1546,6 +2843,25 @@ func (s *Server) reloadHandler(h http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// readLocked calls h while holding the server's lock for reading.
+func (s *Server) readLocked(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		h(w, r)
+	}
+}
+
+// writeLocked calls h while holding the server's lock for writing.
+// It must be used for all handlers that modify the store.
+func (s *Server) writeLocked(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		h(w, r)
+	}
+}
+
 func jsonHandler(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Content-Type") != "application/json" {
@@ -1575,28 +2891,47 @@ func (s *Server) createMux() *http.ServeMux {
 			http.FileServer(http.Dir(path.Join(s.baseDir, "css")))))
 	}
 
-	mux.HandleFunc("GET /kontoo/ledger", s.reloadHandler(s.handleLedger))
-	mux.HandleFunc("GET /kontoo/positions", s.reloadHandler(s.handlePositions))
-	mux.HandleFunc("GET /kontoo/positions/maturing", s.reloadHandler(s.handlePositionsMaturing))
-	mux.HandleFunc("GET /kontoo/positions/equity", s.reloadHandler(s.handlePositionsEquity))
-	mux.HandleFunc("GET /kontoo/entries/new", s.reloadHandler(s.handleEntriesNew))
-	mux.HandleFunc("GET /kontoo/entries/edit/{sequenceNum}", s.reloadHandler(s.handleEntriesEdit))
-	mux.HandleFunc("GET /kontoo/assets/new", s.reloadHandler(s.handleAssetsNew))
-	mux.HandleFunc("GET /kontoo/assets/edit/{assetID}", s.reloadHandler(s.handleAssetsEdit))
-	mux.HandleFunc("GET /kontoo/csv/upload", s.reloadHandler(s.handleCsvUpload))
-	mux.HandleFunc("GET /kontoo/calc", s.reloadHandler(s.handleCalc))
+	mux.HandleFunc("GET /kontoo/ledger", s.readLocked(s.reloadHandler(s.handleLedger)))
+	mux.HandleFunc("GET /kontoo/positions", s.readLocked(s.reloadHandler(s.handlePositions)))
+	mux.HandleFunc("GET /kontoo/positions/maturing", s.readLocked(s.reloadHandler(s.handlePositionsMaturing)))
+	mux.HandleFunc("GET /kontoo/positions/equity", s.readLocked(s.reloadHandler(s.handlePositionsEquity)))
+	mux.HandleFunc("GET /kontoo/entries/new", s.readLocked(s.reloadHandler(s.handleEntriesNew)))
+	mux.HandleFunc("GET /kontoo/entries/edit/{sequenceNum}", s.readLocked(s.reloadHandler(s.handleEntriesEdit)))
+	mux.HandleFunc("GET /kontoo/entries/shift", s.readLocked(s.reloadHandler(s.handleEntriesShift)))
+	mux.HandleFunc("GET /kontoo/assets/new", s.readLocked(s.reloadHandler(s.handleAssetsNew)))
+	mux.HandleFunc("GET /kontoo/assets/edit/{assetID}", s.readLocked(s.reloadHandler(s.handleAssetsEdit)))
+	mux.HandleFunc("GET /kontoo/csv/upload", s.readLocked(s.reloadHandler(s.handleCsvUpload)))
+	mux.HandleFunc("GET /kontoo/calc", s.readLocked(s.reloadHandler(s.handleCalc)))
+	mux.HandleFunc("GET /kontoo/risk", s.featureHandler(FeatureRisk, s.readLocked(s.reloadHandler(s.handleRisk))))
+	mux.HandleFunc("GET /kontoo/reports/gaps", s.readLocked(s.reloadHandler(s.handleReportsGaps)))
+	mux.HandleFunc("GET /kontoo/stats", s.readLocked(s.reloadHandler(s.handleStats)))
+	mux.HandleFunc("GET /kontoo/trash", s.readLocked(s.reloadHandler(s.handleTrash)))
+	mux.HandleFunc("GET /kontoo/custodians", s.featureHandler(FeatureCustodians, s.readLocked(s.reloadHandler(s.handleCustodians))))
+	mux.HandleFunc("GET /kontoo/api/search", s.featureHandler(FeatureSearch, s.readLocked(s.handleSearch)))
 	// TODO: Use different path, e.g. /kontoo/quotes/history? (for consistency)
-	mux.HandleFunc("GET /kontoo/quotes", s.reloadHandler(s.handleQuotes))
-	mux.HandleFunc("POST /kontoo/positions/timeline", jsonHandler(s.handlePositionsTimeline))
-	mux.HandleFunc("POST /kontoo/positions/maturities", jsonHandler(s.handlePositionsMaturities))
-	mux.HandleFunc("POST /kontoo/charts/equity", jsonHandler(s.handleChartsEquity))
-	mux.HandleFunc("POST /kontoo/entries", jsonHandler(s.handleEntriesPost))
-	mux.HandleFunc("POST /kontoo/entries/delete", jsonHandler(s.handleEntriesDelete))
-	mux.HandleFunc("POST /kontoo/entries/assetinfo", jsonHandler(s.handleEntriesAssetInfo))
-	mux.HandleFunc("POST /kontoo/assets", jsonHandler(s.handleAssetsPost))
-	mux.HandleFunc("POST /kontoo/csv", s.handleCsvPost)
-	mux.HandleFunc("POST /kontoo/quotes", jsonHandler(s.handleQuotesPost))
-	mux.HandleFunc("POST /kontoo/calculate", jsonHandler(s.handleCalculate))
+	mux.HandleFunc("GET /kontoo/quotes", s.readLocked(s.reloadHandler(s.handleQuotes)))
+	mux.HandleFunc("POST /kontoo/positions/timeline", s.readLocked(jsonHandler(s.handlePositionsTimeline)))
+	mux.HandleFunc("POST /kontoo/positions/maturities", s.readLocked(jsonHandler(s.handlePositionsMaturities)))
+	mux.HandleFunc("POST /kontoo/positions/interest", s.readLocked(jsonHandler(s.handlePositionsInterest)))
+	mux.HandleFunc("POST /kontoo/charts/equity", s.readLocked(jsonHandler(s.handleChartsEquity)))
+	mux.HandleFunc("POST /kontoo/charts/risk", s.featureHandler(FeatureRisk, s.readLocked(jsonHandler(s.handleChartsRisk))))
+	mux.HandleFunc("POST /kontoo/charts/income", s.readLocked(jsonHandler(s.handleChartsIncome)))
+	mux.HandleFunc("POST /kontoo/entries", s.writeLocked(jsonHandler(s.handleEntriesPost)))
+	mux.HandleFunc("POST /kontoo/entries/delete", s.writeLocked(jsonHandler(s.handleEntriesDelete)))
+	mux.HandleFunc("POST /kontoo/entries/pin", s.writeLocked(jsonHandler(s.handleEntriesPin)))
+	mux.HandleFunc("POST /kontoo/entries/shift", s.writeLocked(jsonHandler(s.handleEntriesShiftPost)))
+	mux.HandleFunc("POST /kontoo/entries/shift/undo", s.writeLocked(jsonHandler(s.handleEntriesShiftUndo)))
+	mux.HandleFunc("POST /kontoo/entries/assetinfo", s.readLocked(jsonHandler(s.handleEntriesAssetInfo)))
+	mux.HandleFunc("POST /kontoo/assets", s.writeLocked(jsonHandler(s.handleAssetsPost)))
+	mux.HandleFunc("POST /kontoo/custodians", s.featureHandler(FeatureCustodians, s.writeLocked(jsonHandler(s.handleCustodiansPost))))
+	mux.HandleFunc("POST /kontoo/custodians/delete", s.featureHandler(FeatureCustodians, s.writeLocked(jsonHandler(s.handleCustodiansDelete))))
+	mux.HandleFunc("POST /kontoo/trash/restore", s.writeLocked(jsonHandler(s.handleTrashRestore)))
+	mux.HandleFunc("POST /kontoo/trash/purge", s.writeLocked(jsonHandler(s.handleTrashPurge)))
+	mux.HandleFunc("POST /kontoo/csv", s.writeLocked(s.handleCsvPost))
+	mux.HandleFunc("POST /kontoo/quotes", s.writeLocked(jsonHandler(s.handleQuotesPost)))
+	mux.HandleFunc("POST /kontoo/quotes/symbols/check", s.featureHandler(FeatureSymbolCheck, jsonHandler(s.handleQuotesSymbolsCheck)))
+	mux.HandleFunc("POST /kontoo/calculate", s.readLocked(jsonHandler(s.handleCalculate)))
+	// ReloadStore acquires the lock itself.
 	mux.HandleFunc("POST /kontoo/ledger/reload", s.reloadHandler(s.handleLedgerReload))
 	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/kontoo/positions", http.StatusTemporaryRedirect)
@@ -1604,6 +2939,23 @@ func (s *Server) createMux() *http.ServeMux {
 	return mux
 }
 
+// symbolCheckAssets returns copies of the assets whose symbols should be
+// checked, or nil if symbol checks are disabled. The copies can be used
+// without holding the server's lock, e.g. while talking to the quote service.
+func (s *Server) symbolCheckAssets() []*Asset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.store.FeatureEnabled(FeatureSymbolCheck) {
+		return nil
+	}
+	assets := s.store.FindAssetsForQuoteService("YF")
+	for i, a := range assets {
+		c := *a
+		assets[i] = &c
+	}
+	return assets
+}
+
 func (s *Server) Serve() error {
 	mux := s.createMux()
 	srv := &http.Server{
@@ -1611,6 +2963,28 @@ func (s *Server) Serve() error {
 		Handler: mux,
 	}
 
+	// Set up the admin socket first: it temporarily changes the process-wide
+	// umask, so no background job may be writing files yet.
+	if s.adminSocket != "" {
+		l, err := s.listenAdmin()
+		if err != nil {
+			return err
+		}
+		adminSrv := &http.Server{Handler: s.createAdminMux()}
+		defer adminSrv.Close()
+		go adminSrv.Serve(l)
+		fmt.Printf("Serving admin interface at %s\n", s.adminSocket)
+	}
+	if s.symbolChecker != nil && s.symbolCheckInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go s.symbolChecker.Run(s.symbolCheckAssets, s.symbolCheckInterval, done)
+	}
+	if s.trashRetention > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go s.runTrashPurger(trashPurgeInterval, done)
+	}
 	fmt.Printf("Running kontoo server at http://%s/ for %s\n", s.addr, s.ledgerPath)
 	return srv.ListenAndServe()
 }
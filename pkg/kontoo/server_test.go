@@ -3,16 +3,21 @@ package kontoo
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"golang.org/x/net/html"
 )
 
@@ -40,7 +45,7 @@ func countElements(n *html.Node, elem string) int {
 func TestHandleLedger(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "/kontoo/ledger", nil)
 	w := httptest.NewRecorder()
-	s, err := NewServer("localhost:8080", "./testdata/testledger.json", "")
+	s, err := NewServer("localhost:8080", "./testdata/testledger.json", "", WithQuoteProvider(NewFakeQuoteProvider()))
 	if err != nil {
 		t.Fatal("Cannot create server:", err)
 	}
@@ -65,7 +70,7 @@ func TestHandleLedger(t *testing.T) {
 func TestHandlePositionsRedirect(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "/kontoo/positions", nil)
 	w := httptest.NewRecorder()
-	s, err := NewServer("localhost:8080", "./testdata/testledger.json", "")
+	s, err := NewServer("localhost:8080", "./testdata/testledger.json", "", WithQuoteProvider(NewFakeQuoteProvider()))
 	if err != nil {
 		t.Fatal("Cannot create server:", err)
 	}
@@ -100,19 +105,26 @@ func copyFile(src, dst string) error {
 	return err
 }
 
-func setupTestServer(t *testing.T) *httptest.Server {
+// newTestServer returns a Server for a temporary copy of the test ledger.
+// Unless opts specify otherwise, it uses an empty FakeQuoteProvider.
+func newTestServer(t *testing.T, opts ...ServerOption) *Server {
 	t.Helper() // For better error reporting
 	// Copy testdata to temp directory and use that as the resource dir,
 	// so that changes can be persisted, but only for the duration of the test.
 	tempDir := t.TempDir()
 	tempLedger := filepath.Join(tempDir, "testledger.json")
 	copyFile("./testdata/testledger.json", tempLedger)
-	s, err := NewServer("localhost:8080", tempLedger, "")
+	opts = append([]ServerOption{WithQuoteProvider(NewFakeQuoteProvider())}, opts...)
+	s, err := NewServer("localhost:8080", tempLedger, "", opts...)
 	if err != nil {
 		t.Fatal("Cannot create server:", err)
 	}
-	srv := httptest.NewServer(s.createMux())
-	return srv
+	return s
+}
+
+func setupTestServer(t *testing.T, opts ...ServerOption) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(newTestServer(t, opts...).createMux())
 }
 
 func TestHandleAddEntriesPostWrongContentType(t *testing.T) {
@@ -144,12 +156,25 @@ func TestHandleGetAllPaths(t *testing.T) {
 	}{
 		{"/", http.StatusOK},
 		{"/kontoo/ledger", http.StatusOK},
+		{"/kontoo/ledger?group=asset&collapsed=NESN", http.StatusOK},
+		{"/kontoo/ledger?group=type", http.StatusBadRequest},
 		{"/kontoo/positions", http.StatusOK},
 		{"/kontoo/positions/maturing", http.StatusOK},
 		{"/kontoo/entries/new", http.StatusOK},
+		{"/kontoo/entries/shift", http.StatusOK},
+		{"/kontoo/entries/shift?q=num:2&days=3", http.StatusOK},
+		{"/kontoo/entries/shift?days=x", http.StatusBadRequest},
 		{"/kontoo/assets/new", http.StatusOK},
 		{"/kontoo/csv/upload", http.StatusOK},
-		// Exclude /kontoo/quotes, as that would trigger Y! finance requests.
+		{"/kontoo/risk", http.StatusOK},
+		{"/kontoo/reports/gaps", http.StatusOK},
+		{"/kontoo/reports/gaps?days=3", http.StatusOK},
+		{"/kontoo/reports/gaps?days=x", http.StatusBadRequest},
+		{"/kontoo/quotes", http.StatusOK},
+		{"/kontoo/stats", http.StatusOK},
+		{"/kontoo/custodians", http.StatusOK},
+		{"/kontoo/custodians?edit=nope", http.StatusNotFound},
+		{"/kontoo/trash", http.StatusOK},
 		{"/kontoo/positions/timeline", http.StatusMethodNotAllowed},
 		{"/kontoo/entries", http.StatusMethodNotAllowed},
 		{"/kontoo/entries/delete", http.StatusMethodNotAllowed},
@@ -226,45 +251,563 @@ func TestHandlePostJson(t *testing.T) {
 	}
 	srv := setupTestServer(t)
 	defer srv.Close()
+	// Every response should have these two fields.
+	type response struct {
+		Status StatusCode `json:"status"`
+		Error  string     `json:"error"`
+	}
 	for _, tc := range tests {
-		var buf bytes.Buffer
-		err := json.NewEncoder(&buf).Encode(tc.data)
-		if err != nil {
-			t.Fatal("Cannot marshal JSON:", err)
+		r := postJSON[response](t, srv.URL+tc.path, tc.data)
+		if r.Status != StatusOK {
+			t.Errorf("Wrong status in response: want OK, got %v. Error: %q", r.Status, r.Error)
 		}
-		resp, err := http.Post(srv.URL+tc.path, "application/json", &buf)
-		if err != nil {
-			t.Fatal("Post failed:", err)
+	}
+}
+
+func TestHandlePositionsTimelinePeriod(t *testing.T) {
+	end := DateVal(2024, 12, 31)
+	tests := []struct {
+		period    string
+		wantStart Date
+	}{
+		{"1Y", DateVal(2023, 12, 31)},
+		{"3m", DateVal(2024, 10, 1)}, // Sep 31 normalizes to Oct 1.
+		{"YTD", DateVal(2024, 1, 1)},
+		// Start of the NESN position in the test ledger.
+		{"Max", DateVal(2024, 1, 2)},
+	}
+	srv := setupTestServer(t)
+	defer srv.Close()
+	for _, tc := range tests {
+		r := postJSON[PositionTimelineResponse](t, srv.URL+"/kontoo/positions/timeline", &PositionTimelineRequest{
+			AssetIDs:     []string{"NESN"},
+			EndTimestamp: end.UnixMilli(),
+			Period:       tc.period,
+		})
+		if r.Status != StatusOK {
+			t.Fatalf("Wrong status in response: want OK, got %v. Error: %q", r.Status, r.Error)
+		}
+		if r.StartTimestamp != tc.wantStart.UnixMilli() {
+			t.Errorf("Wrong start for period %s: want %v, got %v", tc.period,
+				tc.wantStart, time.UnixMilli(r.StartTimestamp).UTC())
 		}
+		if r.EndTimestamp != end.UnixMilli() {
+			t.Errorf("Wrong end for period %s: want %v, got %v", tc.period,
+				end, time.UnixMilli(r.EndTimestamp).UTC())
+		}
+	}
+}
+
+func TestHandleChartsIncome(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Close()
+	r := postJSON[IncomeChartResponse](t, srv.URL+"/kontoo/charts/income", &IncomeChartRequest{EndTimestamp: DateVal(2024, 6, 30).UnixMilli()})
+	if r.Status != StatusOK {
+		t.Fatalf("Wrong status: %v. Error: %q", r.Status, r.Error)
+	}
+	if diff := cmp.Diff([]int{2022, 2023, 2024}, r.Years); diff != "" {
+		t.Errorf("Wrong years (-want +got): %s", diff)
+	}
+	if len(r.Months) != 12 || r.Months[0] != "Jan" {
+		t.Errorf("Wrong months: %v", r.Months)
+	}
+	if len(r.TotalMicros) != 3 || len(r.TotalMicros[0]) != 12 {
+		t.Errorf("Wrong dimensions of TotalMicros: %v", r.TotalMicros)
+	}
+	if r := postJSON[IncomeChartResponse](t, srv.URL+"/kontoo/charts/income", &IncomeChartRequest{Years: -1}); r.Status != StatusInvalidArgument {
+		t.Errorf("Wrong status for invalid years: %v", r.Status)
+	}
+}
+
+func TestHandlePositionsInterest(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Close()
+	r := postJSON[AverageInterestResponse](t, srv.URL+"/kontoo/positions/interest", &AverageInterestRequest{EndTimestamp: DateVal(2024, 6, 15).UnixMilli(), Period: "3M"})
+	if r.Status != StatusOK {
+		t.Fatalf("Wrong status: %v. Error: %q", r.Status, r.Error)
+	}
+	// Month ends of March, April, May, plus the end date.
+	if len(r.Timestamps) != 4 || len(r.InterestMicros) != 4 || len(r.ValueMicros) != 4 {
+		t.Errorf("Wrong number of data points: %d", len(r.Timestamps))
+	}
+	if r.Currency != "EUR" {
+		t.Errorf("Wrong currency: %q", r.Currency)
+	}
+	if r := postJSON[AverageInterestResponse](t, srv.URL+"/kontoo/positions/interest", &AverageInterestRequest{Period: "Max"}); r.Status != StatusOK {
+		t.Errorf("Wrong status for Max period: %v. Error: %q", r.Status, r.Error)
+	}
+	if r := postJSON[AverageInterestResponse](t, srv.URL+"/kontoo/positions/interest", &AverageInterestRequest{Period: "3X"}); r.Status != StatusInvalidArgument {
+		t.Errorf("Wrong status for invalid period: %v", r.Status)
+	}
+}
+
+func TestHandleChartsCSV(t *testing.T) {
+	end := DateVal(2024, 12, 31).UnixMilli()
+	tests := []struct {
+		path       string
+		data       any
+		wantHeader string
+	}{
+		{
+			path: "/kontoo/positions/timeline?format=csv",
+			data: &PositionTimelineRequest{
+				AssetIDs:     []string{"NESN"},
+				EndTimestamp: end,
+				Period:       "Max",
+			},
+			wantHeader: "AssetID,AssetName,Date,Quantity,Value",
+		},
+		{
+			path:       "/kontoo/positions/maturities?format=csv",
+			data:       &PositionsMaturitiesRequest{EndTimestamp: end},
+			wantHeader: "YearsToMaturity,Currency,Value",
+		},
+		{
+			path:       "/kontoo/charts/equity?format=csv",
+			data:       &EquityChartRequest{EndTimestamp: end},
+			wantHeader: "AssetName,Currency,Value",
+		},
+		{
+			path:       "/kontoo/charts/risk?format=csv",
+			data:       &RiskChartRequest{EndTimestamp: end},
+			wantHeader: "Category,Currency,",
+		},
+		{
+			path:       "/kontoo/charts/income?format=csv",
+			data:       &IncomeChartRequest{EndTimestamp: end, Years: 2},
+			wantHeader: "Year,Month,Currency,Interest,Dividends,Total",
+		},
+		{
+			path:       "/kontoo/positions/interest?format=csv",
+			data:       &AverageInterestRequest{EndTimestamp: end, Period: "1Y"},
+			wantHeader: "Date,Currency,Value,Interest",
+		},
+	}
+	srv := setupTestServer(t)
+	defer srv.Close()
+	for _, tc := range tests {
+		resp := postJSONResponse(t, srv.URL+tc.path, tc.data)
 		defer resp.Body.Close()
 		if resp.StatusCode != http.StatusOK {
 			t.Fatalf("Expected OK status for path %q, got %d", tc.path, resp.StatusCode)
 		}
-		// Every response should have these two fields.
-		var r struct {
-			Status StatusCode `json:"status"`
-			Error  string     `json:"error"`
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+			t.Errorf("Wrong Content-Type for path %q: %q", tc.path, ct)
 		}
-		err = json.NewDecoder(resp.Body).Decode(&r)
+		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			t.Fatalf("Cannot decode response: %v", err)
+			t.Fatal("Cannot read body:", err)
 		}
-		if r.Status != StatusOK {
-			t.Errorf("Wrong status in response: want OK, got %v. Error: %q", r.Status, r.Error)
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		if len(lines) < 2 {
+			t.Errorf("Expected header and data rows for path %q, got %q", tc.path, string(body))
+			continue
+		}
+		if !strings.HasPrefix(lines[0], tc.wantHeader) {
+			t.Errorf("Wrong CSV header for path %q: want prefix %q, got %q", tc.path, tc.wantHeader, lines[0])
 		}
 	}
 }
 
-func TestHandlePostCsvUpload(t *testing.T) {
-	// Uploads the testdata/positions.csv file as multipart/form-data
-	// (like the UI does), and checks that it is processed succesfully.
+func TestHandleQuotes(t *testing.T) {
+	fake := NewFakeQuoteProvider()
+	fake.Quotes["NESN.SW"] = &DailyQuote{
+		Symbol:       "NESN.SW",
+		Currency:     "CHF",
+		ClosingPrice: 87_650_000,
+	}
+	fake.Quotes["EURCHF=X"] = &DailyQuote{
+		Symbol:       "EURCHF=X",
+		Currency:     "CHF",
+		ClosingPrice: 940_000,
+	}
+	srv := setupTestServer(t, WithQuoteProvider(fake))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/kontoo/quotes?date=2024-06-03")
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal("Cannot read body:", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Wrong status: %d. Body was: %s", resp.StatusCode, body)
+	}
+	for _, want := range []string{"NESN.SW", "87.65", "0.94"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Response does not contain %q", want)
+		}
+	}
+}
+
+func TestHandleQuotesCurrencyMismatch(t *testing.T) {
+	fake := NewFakeQuoteProvider()
+	// NESN is recorded in CHF in the test ledger.
+	fake.Quotes["NESN.SW"] = &DailyQuote{
+		Symbol:       "NESN.SW",
+		Currency:     "EUR",
+		ClosingPrice: 100 * UnitValue,
+	}
+	srv := setupTestServer(t, WithQuoteProvider(fake))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/kontoo/quotes?date=2024-06-03")
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "currency-mismatch") {
+		t.Error("Quote with mismatching currency not marked as such")
+	}
+	// EUR/CHF is 0.95 in the test ledger.
+	if !strings.Contains(string(body), `data-converted-price="95000000"`) {
+		t.Error("Converted price missing")
+	}
+}
+
+func TestHandleQuotesPostCurrencyMismatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		item       *AddQuoteItem
+		wantStatus StatusCode
+	}{
+		{
+			name:       "same_currency",
+			item:       &AddQuoteItem{AssetID: "NESN", Date: DateVal(2024, 6, 3), PriceMicros: 90 * UnitValue, Currency: "CHF"},
+			wantStatus: StatusOK,
+		},
+		{
+			name:       "no_currency",
+			item:       &AddQuoteItem{AssetID: "NESN", Date: DateVal(2024, 6, 3), PriceMicros: 90 * UnitValue},
+			wantStatus: StatusOK,
+		},
+		{
+			name:       "mismatch",
+			item:       &AddQuoteItem{AssetID: "NESN", Date: DateVal(2024, 6, 3), PriceMicros: 100 * UnitValue, Currency: "EUR"},
+			wantStatus: StatusInvalidArgument,
+		},
+		{
+			name:       "mismatch_convert",
+			item:       &AddQuoteItem{AssetID: "NESN", Date: DateVal(2024, 6, 3), PriceMicros: 100 * UnitValue, Currency: "EUR", Convert: true},
+			wantStatus: StatusOK,
+		},
+		{
+			name:       "mismatch_no_rate",
+			item:       &AddQuoteItem{AssetID: "NESN", Date: DateVal(2024, 6, 3), PriceMicros: 100 * UnitValue, Currency: "GBP", Convert: true},
+			wantStatus: StatusInvalidArgument,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+			srv := httptest.NewServer(s.createMux())
+			defer srv.Close()
+			r := postJSON[AddQuotesResponse](t, srv.URL+"/kontoo/quotes", &AddQuotesRequest{Quotes: []*AddQuoteItem{tc.item}})
+			if r.Status != tc.wantStatus {
+				t.Fatalf("Wrong status: want %v, got %v (error: %q)", tc.wantStatus, r.Status, r.Error)
+			}
+			price, _, _ := s.Store().PriceAt("NESN", tc.item.Date)
+			want := Micros(98 * UnitValue) // Purchase price in the test ledger.
+			if tc.wantStatus == StatusOK {
+				want = tc.item.PriceMicros
+				if tc.item.Convert {
+					want = 95 * UnitValue
+				}
+			}
+			if price != want {
+				t.Errorf("Wrong price in ledger: want %v, got %v", want, price)
+			}
+		})
+	}
+}
+
+func TestHandleQuotesUnavailable(t *testing.T) {
+	fake := NewFakeQuoteProvider()
+	fake.Err = &url.Error{Op: "Get", URL: "https://example.com", Err: errors.New("offline")}
+	srv := setupTestServer(t, WithQuoteProvider(fake))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/kontoo/quotes?date=2024-06-03")
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Wrong status: %d. Body was: %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(string(body), "offline") {
+		t.Errorf("Response does not contain error message: %s", body)
+	}
+}
+
+// blockingQuoteProvider blocks FetchQuoteSummary calls until release is closed.
+type blockingQuoteProvider struct {
+	*FakeQuoteProvider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingQuoteProvider) FetchQuoteSummary(symbol string) (*YFQuoteSummaryResponse, error) {
+	p.started <- struct{}{}
+	<-p.release
+	return p.FakeQuoteProvider.FetchQuoteSummary(symbol)
+}
+
+func TestHandleQuotesSymbolsCheckDoesNotBlockWriters(t *testing.T) {
+	p := &blockingQuoteProvider{
+		FakeQuoteProvider: NewFakeQuoteProvider(),
+		started:           make(chan struct{}),
+		release:           make(chan struct{}),
+	}
+	srv := setupTestServer(t, WithQuoteProvider(p))
+	defer srv.Close()
+	done := make(chan CheckSymbolsResponse)
+	go func() {
+		resp, err := http.Post(srv.URL+"/kontoo/quotes/symbols/check", "application/json", nil)
+		if err != nil {
+			t.Error("Post failed:", err)
+			close(done)
+			return
+		}
+		defer resp.Body.Close()
+		var r CheckSymbolsResponse
+		json.NewDecoder(resp.Body).Decode(&r)
+		done <- r
+	}()
+	<-p.started
+	// The symbol check is waiting for the quote service. Writers must not be blocked.
+	if r := postJSON[PinLedgerEntryResponse](t, srv.URL+"/kontoo/entries/pin", &PinLedgerEntryRequest{SequenceNum: 1, Pinned: true}); r.Status != StatusOK {
+		t.Errorf("Wrong status for pin: %v. Error: %q", r.Status, r.Error)
+	}
+	close(p.release)
+	if r := <-done; r.Status != StatusOK || r.NumChecked != 1 {
+		t.Errorf("Wrong symbol check response: %+v", r)
+	}
+}
+
+func TestHandleQuotesSymbolsCheck(t *testing.T) {
+	// The fake provider knows no symbols, so NESN.SW from the test ledger is broken.
+	s := newTestServer(t)
+	srv := httptest.NewServer(s.createMux())
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+"/kontoo/quotes/symbols/check", "application/json", nil)
+	if err != nil {
+		t.Fatal("Post failed:", err)
+	}
+	defer resp.Body.Close()
+	var r CheckSymbolsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		t.Fatal("Cannot decode response:", err)
+	}
+	if r.Status != StatusOK {
+		t.Fatalf("Wrong status: %v. Error: %q", r.Status, r.Error)
+	}
+	if r.NumChecked != 1 || len(r.Broken) != 1 || r.Broken[0].Symbol != "NESN.SW" {
+		t.Errorf("Wrong response: %+v", r)
+	}
+	// Broken symbols are flagged on the quotes page.
+	resp2, err := http.Get(srv.URL + "/kontoo/quotes?date=2024-06-03")
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	defer resp2.Body.Close()
+	body, _ := io.ReadAll(resp2.Body)
+	if !strings.Contains(string(body), "did not find the following symbols") {
+		t.Errorf("Quotes page does not flag broken symbols: %s", body)
+	}
+}
+
+func TestOfflineMode(t *testing.T) {
+	s, err := NewServer("localhost:8080", "./testdata/testledger.json", "", WithOffline())
+	if err != nil {
+		t.Fatal("Cannot create server:", err)
+	}
+	if s.quoteProvider != nil {
+		t.Fatal("Expected no quote provider in offline mode")
+	}
+	srv := httptest.NewServer(s.createMux())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/kontoo/quotes?date=2024-06-03")
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Wrong status: %d. Body was: %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(string(body), "offline mode") {
+		t.Error("Quotes page does not mention offline mode")
+	}
+	if strings.Contains(string(body), "https://fonts.") {
+		t.Error("Quotes page loads external fonts in offline mode")
+	}
+}
+
+func TestHandleAssetsPostTimezone(t *testing.T) {
+	fake := NewFakeQuoteProvider()
+	fake.Timezones["AAPL"] = "America/New_York"
+	s := newTestServer(t, WithQuoteProvider(fake))
+	srv := httptest.NewServer(s.createMux())
+	defer srv.Close()
+	r := postJSON[UpsertAssetResponse](t, srv.URL+"/kontoo/assets", &UpsertAssetRequest{
+		Asset: &Asset{
+			Type:                Stock,
+			Name:                "Apple Inc.",
+			TickerSymbol:        "AAPL",
+			QuoteServiceSymbols: map[string]string{"YF": "AAPL"},
+			Currency:            "USD",
+		},
+	})
+	if r.Status != StatusOK {
+		t.Fatalf("Wrong status: %v. Error: %q", r.Status, r.Error)
+	}
+	a, ok := s.Store().assets["AAPL"]
+	if !ok {
+		t.Fatal("Asset was not added")
+	}
+	if a.ExchangeTimezone != "America/New_York" {
+		t.Errorf("Wrong exchange timezone: %q", a.ExchangeTimezone)
+	}
+}
+
+func TestLedgerEntryRowGroups(t *testing.T) {
+	s := newTestServer(t)
+	query, err := ParseQuery("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := s.Store().LedgerEntryRows(query)
+	u, _ := url.Parse("/kontoo/ledger?group=asset&collapsed=NESN&snippet=true")
+	groups := ledgerEntryRowGroups(rows, u)
+	n := 0
+	for _, g := range groups {
+		n += len(g.Rows)
+		for _, r := range g.Rows {
+			if k := ledgerEntryRowGroupKey(r); k != g.Key {
+				t.Errorf("Row with key %q in group %q", k, g.Key)
+			}
+		}
+		tu, err := url.Parse(g.ToggleURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tq := tu.Query()
+		if tq.Has("snippet") {
+			t.Errorf("ToggleURL must not contain snippet param: %s", g.ToggleURL)
+		}
+		if g.Key == "NESN" {
+			if !g.Collapsed {
+				t.Error("NESN group should be collapsed")
+			}
+			if len(tq["collapsed"]) != 0 {
+				t.Errorf("ToggleURL should expand NESN: %s", g.ToggleURL)
+			}
+		} else if g.Collapsed || !slices.Contains(tq["collapsed"], g.Key) {
+			t.Errorf("Group %q should be expanded and have a collapsing ToggleURL: %s", g.Key, g.ToggleURL)
+		}
+	}
+	if n != len(rows) {
+		t.Errorf("Groups contain %d rows, want %d", n, len(rows))
+	}
+}
+
+func TestLedgerEntryRowGroupTotals(t *testing.T) {
+	const u = UnitValue
+	row := func(typ EntryType, value, cost Micros) *LedgerEntryRow {
+		return &LedgerEntryRow{E: &LedgerEntry{Type: typ, ValueMicros: value, CostMicros: cost}}
+	}
+	tests := []struct {
+		name      string
+		rows      []*LedgerEntryRow
+		wantValue Micros
+		wantCost  Micros
+	}{
+		{
+			name: "account",
+			rows: []*LedgerEntryRow{
+				row(AccountBalance, 1000*u, 0),
+				row(AccountCredit, 200*u, 0),
+				row(AccountBalance, 1200*u, 0),
+				row(InterestPayment, 5*u, 0),
+				row(AccountDebit, -50*u, 0),
+				row(AccountBalance, 1155*u, 0),
+			},
+			wantValue: 155 * u,
+		},
+		{
+			name: "stock",
+			rows: []*LedgerEntryRow{
+				row(AssetPurchase, 1000*u, 10*u),
+				row(AssetPrice, 0, 0),
+				row(AssetHolding, 1100*u, 10*u),
+				row(DividendPayment, 30*u, 0),
+				row(AssetSale, -600*u, 5*u),
+			},
+			wantValue: 430 * u,
+			wantCost:  15 * u,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := &LedgerEntryRowGroup{Rows: tc.rows}
+			if got := g.TotalValue(); got != tc.wantValue {
+				t.Errorf("TotalValue: got %v, want %v", got, tc.wantValue)
+			}
+			if got := g.TotalCost(); got != tc.wantCost {
+				t.Errorf("TotalCost: got %v, want %v", got, tc.wantCost)
+			}
+		})
+	}
+}
+
+func TestHandleCustodiansPost(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s.createMux())
+	defer srv.Close()
+	r := postJSON[UpsertCustodianResponse](t, srv.URL+"/kontoo/custodians", &UpsertCustodianRequest{
+		Custodian: &Custodian{ID: "cd", Name: "Comdirect", CustodyFeeMicros: 2500},
+	})
+	if r.Status != StatusOK || r.CustodianID != "cd" {
+		t.Fatalf("Wrong response: %+v", r)
+	}
+	r = postJSON[UpsertCustodianResponse](t, srv.URL+"/kontoo/custodians", &UpsertCustodianRequest{
+		CustodianID: "cd",
+		Custodian:   &Custodian{ID: "cd", Name: "Comdirect Bank"},
+	})
+	if r.Status != StatusOK {
+		t.Fatalf("Wrong status for update: %v. Error: %q", r.Status, r.Error)
+	}
+	if c := s.Store().FindCustodian("cd"); c == nil || c.Name != "Comdirect Bank" {
+		t.Errorf("Custodian was not updated: %v", c)
+	}
+	r = postJSON[UpsertCustodianResponse](t, srv.URL+"/kontoo/custodians", &UpsertCustodianRequest{
+		Custodian: &Custodian{ID: "cd", Name: "Duplicate"},
+	})
+	if r.Status != StatusInvalidArgument {
+		t.Errorf("Wrong status for duplicate: %v", r.Status)
+	}
+	dr := postJSON[DeleteCustodianResponse](t, srv.URL+"/kontoo/custodians/delete", &DeleteCustodianRequest{CustodianID: "cd"})
+	if dr.Status != StatusOK {
+		t.Fatalf("Wrong status for delete: %v. Error: %q", dr.Status, dr.Error)
+	}
+	if c := s.Store().FindCustodian("cd"); c != nil {
+		t.Errorf("Custodian was not deleted: %v", c)
+	}
+}
+
+// postCsvFile uploads the given file as multipart/form-data (like the UI does).
+func postCsvFile(t *testing.T, url string, file string) CsvUploadResponse {
+	t.Helper()
 	var body bytes.Buffer
 	w := multipart.NewWriter(&body)
 	filePart, err := w.CreateFormFile("file", "test.csv")
 	if err != nil {
 		t.Fatal("Cannot create form file:", err)
 	}
-	csvFile, err := os.Open("./testdata/positions.csv")
+	csvFile, err := os.Open(file)
 	if err != nil {
 		t.Fatal("Could not open CSV file:", err)
 	}
@@ -274,11 +817,8 @@ func TestHandlePostCsvUpload(t *testing.T) {
 		t.Fatal("Cannot copy to file part:", err)
 	}
 	w.Close()
-	// Start the server.
-	srv := setupTestServer(t)
-	defer srv.Close()
 	// Send the multipart/form-data request.
-	req, err := http.NewRequest(http.MethodPost, srv.URL+"/kontoo/csv", &body)
+	req, err := http.NewRequest(http.MethodPost, url, &body)
 	if err != nil {
 		t.Fatal("Failed to create request:", err)
 	}
@@ -298,6 +838,243 @@ func TestHandlePostCsvUpload(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Cannot decode response: %v. Response was: %q", err, string(data))
 	}
+	return r
+}
+
+// postJSONResponse posts req as JSON to url and returns the response.
+// The caller must close the response body.
+func postJSONResponse(t *testing.T, url string, req any) *http.Response {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(req); err != nil {
+		t.Fatal("Cannot marshal JSON:", err)
+	}
+	resp, err := http.Post(url, "application/json", &buf)
+	if err != nil {
+		t.Fatal("Post failed:", err)
+	}
+	return resp
+}
+
+// postJSON posts req as JSON to url and decodes the JSON response.
+func postJSON[Resp any](t *testing.T, url string, req any) Resp {
+	t.Helper()
+	resp := postJSONResponse(t, url, req)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected OK status for %q, got %d", url, resp.StatusCode)
+	}
+	var r Resp
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		t.Fatal("Cannot decode response:", err)
+	}
+	return r
+}
+
+func TestHandleEntriesPin(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s.createMux())
+	defer srv.Close()
+	if r := postJSON[PinLedgerEntryResponse](t, srv.URL+"/kontoo/entries/pin", &PinLedgerEntryRequest{SequenceNum: 2, Pinned: true}); r.Status != StatusOK {
+		t.Fatalf("Wrong status: %v. Error: %q", r.Status, r.Error)
+	}
+	if r := postJSON[PinLedgerEntryResponse](t, srv.URL+"/kontoo/entries/pin", &PinLedgerEntryRequest{SequenceNum: 1000, Pinned: true}); r.Status != StatusInvalidArgument {
+		t.Errorf("Wrong status for unknown entry: %v", r.Status)
+	}
+	// Bookmarks are shown in the navigation bar of every page.
+	resp, err := http.Get(srv.URL + "/kontoo/stats")
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "q=num%3A2") {
+		t.Error("Bookmark link not found in page")
+	}
+}
+
+func TestHandleEntriesPostBalanceAlert(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Close()
+	// The test ledger has a balance of 1000 EUR for DE13123.
+	entry := &LedgerEntry{
+		Type:        AccountBalance,
+		AssetID:     "DE13123",
+		ValueDate:   DateVal(2024, 2, 1),
+		ValueMicros: 10_000 * UnitValue,
+	}
+	r := postJSON[UpsertLedgerEntryResponse](t, srv.URL+"/kontoo/entries", &UpsertLedgerEntryRequest{Entry: entry})
+	if r.Status != StatusConfirmationRequired {
+		t.Fatalf("Wrong status: %v. Error: %q", r.Status, r.Error)
+	}
+	if !strings.Contains(r.Error, "10'000.00 EUR") {
+		t.Errorf("Error does not mention the new balance: %q", r.Error)
+	}
+	r = postJSON[UpsertLedgerEntryResponse](t, srv.URL+"/kontoo/entries", &UpsertLedgerEntryRequest{Entry: entry, Confirmed: true})
+	if r.Status != StatusOK {
+		t.Fatalf("Wrong status: %v. Error: %q", r.Status, r.Error)
+	}
+}
+
+func TestHandleEntriesShift(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s.createMux())
+	defer srv.Close()
+	if r := postJSON[ShiftDatesResponse](t, srv.URL+"/kontoo/entries/shift", &ShiftDatesRequest{Query: "", Days: 1}); r.Status != StatusInvalidArgument {
+		t.Errorf("Wrong status for empty query: %v", r.Status)
+	}
+	r := postJSON[ShiftDatesResponse](t, srv.URL+"/kontoo/entries/shift", &ShiftDatesRequest{Query: "num:3-4", Days: -2})
+	if r.Status != StatusOK {
+		t.Fatalf("Wrong status: %v. Error: %q", r.Status, r.Error)
+	}
+	if r.NumShifted != 2 {
+		t.Errorf("Wrong number of shifted entries: %d", r.NumShifted)
+	}
+	if got := s.Store().FindEntryBySequenceNum(4).ValueDate; got != DateVal(2024, 1, 1) {
+		t.Errorf("Wrong value date after shift: %v", got)
+	}
+	if r := postJSON[ShiftDatesResponse](t, srv.URL+"/kontoo/entries/shift/undo", struct{}{}); r.Status != StatusOK {
+		t.Fatalf("Wrong status for undo: %v. Error: %q", r.Status, r.Error)
+	}
+	if got := s.Store().FindEntryBySequenceNum(4).ValueDate; got != DateVal(2024, 1, 3) {
+		t.Errorf("Wrong value date after undo: %v", got)
+	}
+	if r := postJSON[ShiftDatesResponse](t, srv.URL+"/kontoo/entries/shift/undo", struct{}{}); r.Status != StatusFailedPrecondition {
+		t.Errorf("Wrong status for second undo: %v", r.Status)
+	}
+}
+
+func TestLedgerSettingsNav(t *testing.T) {
+	s := newTestServer(t)
+	s.Store().ledger.Header.Settings = &LedgerSettings{
+		HiddenPages: []string{"calc"},
+		CustomLinks: []*NavLink{{Title: "My bank", URL: "https://bank.example.com"}},
+		Features:    map[string]bool{FeatureRisk: false},
+	}
+	srv := httptest.NewServer(s.createMux())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/kontoo/stats")
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	page := string(body)
+	if strings.Contains(page, `href="/kontoo/calc"`) {
+		t.Error("Hidden page calc is shown in nav")
+	}
+	if strings.Contains(page, `href="/kontoo/risk"`) {
+		t.Error("Page of disabled feature is shown in nav")
+	}
+	if !strings.Contains(page, `href="https://bank.example.com"`) {
+		t.Error("Custom link is missing in nav")
+	}
+	if err := validateSettings(s.Store().Settings()); err != nil {
+		t.Error("Valid settings rejected:", err)
+	}
+	if err := validateSettings(&LedgerSettings{HiddenPages: []string{"nosuchpage"}}); err == nil {
+		t.Error("Expected error for unknown hidden page")
+	}
+	// Hidden pages are still accessible, pages of disabled features are not.
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/kontoo/calc", http.StatusOK},
+		{"/kontoo/risk", http.StatusNotFound},
+		{"/kontoo/custodians", http.StatusOK},
+	}
+	for _, tc := range tests {
+		resp, err := http.Get(srv.URL + tc.path)
+		if err != nil {
+			t.Fatal("Get failed:", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("Wrong status for %s: want %d, got %d", tc.path, tc.status, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandleTrash(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s.createMux())
+	defer srv.Close()
+	numEntries := len(s.Store().ledger.Entries)
+	seq := int64(1)
+	if r := postJSON[DeleteLedgerEntryResponse](t, srv.URL+"/kontoo/entries/delete", &DeleteLedgerEntryRequest{SequenceNum: &seq}); r.Status != StatusOK {
+		t.Fatalf("Wrong status for delete: %v. Error: %q", r.Status, r.Error)
+	}
+	resp, err := http.Get(srv.URL + "/kontoo/trash")
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `data-seq="1"`) {
+		t.Error("Trashed entry not shown on trash page")
+	}
+	if r := postJSON[TrashEntryResponse](t, srv.URL+"/kontoo/trash/restore", &TrashEntryRequest{SequenceNum: &seq}); r.Status != StatusOK {
+		t.Fatalf("Wrong status for restore: %v. Error: %q", r.Status, r.Error)
+	}
+	if n := len(s.Store().ledger.Entries); n != numEntries {
+		t.Errorf("Wrong number of entries after restore: want %d, got %d", numEntries, n)
+	}
+	if r := postJSON[TrashEntryResponse](t, srv.URL+"/kontoo/trash/restore", &TrashEntryRequest{SequenceNum: &seq}); r.Status != StatusInvalidArgument {
+		t.Errorf("Wrong status for restoring non-trashed entry: %v", r.Status)
+	}
+	postJSON[DeleteLedgerEntryResponse](t, srv.URL+"/kontoo/entries/delete", &DeleteLedgerEntryRequest{SequenceNum: &seq})
+	if r := postJSON[TrashEntryResponse](t, srv.URL+"/kontoo/trash/purge", &TrashEntryRequest{All: true}); r.Status != StatusOK || r.NumPurged != 1 {
+		t.Errorf("Wrong response for purge: %+v", r)
+	}
+	if n := len(s.Store().Trash()); n != 0 {
+		t.Errorf("Trash not empty after purge: %d", n)
+	}
+}
+
+func TestHandleTrashGetIsReadOnly(t *testing.T) {
+	s := newTestServer(t, WithTrashRetention(24*time.Hour))
+	srv := httptest.NewServer(s.createMux())
+	defer srv.Close()
+	if err := s.Store().Delete(1); err != nil {
+		t.Fatal("Delete failed:", err)
+	}
+	s.Store().Trash()[0].Deleted = time.Now().Add(-48 * time.Hour)
+	fi, err := os.Stat(s.ledgerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(srv.URL + "/kontoo/trash")
+	if err != nil {
+		t.Fatal("Get failed:", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Wrong status: %s", resp.Status)
+	}
+	if strings.Contains(string(body), `data-seq="1"`) {
+		t.Error("Expired entry shown on trash page")
+	}
+	if n := len(s.Store().Trash()); n != 1 {
+		t.Errorf("GET must not purge the trash, got %d entries", n)
+	}
+	if fi2, err := os.Stat(s.ledgerPath); err != nil || !fi2.ModTime().Equal(fi.ModTime()) {
+		t.Error("GET must not save the ledger")
+	}
+	if n, err := s.purgeExpiredTrash(); err != nil || n != 1 {
+		t.Errorf("purgeExpiredTrash: got (%d, %v), want (1, nil)", n, err)
+	}
+	if n := len(s.Store().Trash()); n != 0 {
+		t.Errorf("Trash not empty after purge: %d", n)
+	}
+}
+
+func TestHandlePostCsvUpload(t *testing.T) {
+	// Uploads the testdata/positions.csv file and checks that it is processed succesfully.
+	srv := setupTestServer(t)
+	defer srv.Close()
+	r := postCsvFile(t, srv.URL+"/kontoo/csv", "./testdata/positions.csv")
 	if r.Status != StatusOK {
 		t.Errorf("Wrong status in response: want OK, got %v. Error: %q", r.Status, r.Error)
 	}
@@ -308,3 +1085,57 @@ func TestHandlePostCsvUpload(t *testing.T) {
 		t.Error("InnerHTML is missing")
 	}
 }
+
+func TestHandlePostCsvUploadImportProfile(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s.createMux())
+	defer srv.Close()
+	file := "./testdata/positions.csv"
+	r := postCsvFile(t, srv.URL+"/kontoo/csv", file)
+	if r.Status != StatusOK {
+		t.Fatalf("Wrong status: %v. Error: %q", r.Status, r.Error)
+	}
+	if r.KnownFormat {
+		t.Error("First upload should not use a known format")
+	}
+	if r.ImportProfile == nil || r.ImportProfile.Encoding != EncodingISO8859_15 {
+		t.Fatalf("Wrong import profile: %+v", r.ImportProfile)
+	}
+	// Remember the imported asset.
+	qr := postJSON[AddQuotesResponse](t, srv.URL+"/kontoo/quotes", &AddQuotesRequest{
+		Quotes: []*AddQuoteItem{
+			{AssetID: "710000", Date: DateVal(2024, 3, 17), PriceMicros: 58_740_000},
+		},
+		ImportSignature: r.ImportProfile.Signature,
+	})
+	if qr.Status != StatusOK {
+		t.Fatalf("Wrong status for quotes: %v. Error: %q", qr.Status, qr.Error)
+	}
+	modified := s.Store().ImportProfile(r.ImportProfile.Signature).Modified
+	fi, err := os.Stat(s.ledgerPath)
+	if err != nil {
+		t.Fatal("Cannot stat ledger:", err)
+	}
+	r = postCsvFile(t, srv.URL+"/kontoo/csv", file)
+	if !r.KnownFormat {
+		t.Error("Second upload should use the known format")
+	}
+	if diff := cmp.Diff([]string{"710000"}, r.ImportProfile.AssetIDs); diff != "" {
+		t.Errorf("Wrong asset IDs (-want +got): %s", diff)
+	}
+	// Uploads with unchanged settings do not store the profile again.
+	if got := s.Store().ImportProfile(r.ImportProfile.Signature).Modified; !got.Equal(modified) {
+		t.Errorf("Unchanged profile was modified: want %v, got %v", modified, got)
+	}
+	if fi2, err := os.Stat(s.ledgerPath); err != nil || !fi2.ModTime().Equal(fi.ModTime()) {
+		t.Error("Upload with unchanged profile must not save the ledger")
+	}
+	// Settings are persisted in the ledger.
+	stored, err := LoadStore(s.ledgerPath)
+	if err != nil {
+		t.Fatal("Cannot load store:", err)
+	}
+	if n := len(stored.ledger.Header.ImportProfiles); n != 1 {
+		t.Errorf("Wrong number of import profiles in saved ledger: %d", n)
+	}
+}
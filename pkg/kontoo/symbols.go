@@ -0,0 +1,132 @@
+package kontoo
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SymbolStatus is the result of checking an asset's quote service symbol.
+type SymbolStatus struct {
+	AssetID   string
+	AssetName string
+	Symbol    string
+	Checked   time.Time
+	// Set if the quote service does not know the symbol (anymore),
+	// e.g. because the security was delisted.
+	NotFound bool
+}
+
+// SymbolChecker checks that the quote service symbols of assets still
+// resolve at the quote provider. It remembers the latest result for each asset.
+type SymbolChecker struct {
+	provider QuoteProvider
+	mut      sync.Mutex
+	results  map[string]*SymbolStatus // Keyed by asset ID.
+	lastRun  time.Time
+}
+
+func NewSymbolChecker(provider QuoteProvider) *SymbolChecker {
+	return &SymbolChecker{
+		provider: provider,
+		results:  make(map[string]*SymbolStatus),
+	}
+}
+
+func (c *SymbolChecker) record(a *Asset, symbol string, notFound bool) *SymbolStatus {
+	st := &SymbolStatus{
+		AssetID:   a.ID(),
+		AssetName: a.Name,
+		Symbol:    symbol,
+		Checked:   time.Now(),
+		NotFound:  notFound,
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.results[st.AssetID] = st
+	return st
+}
+
+// RecordError records the result of a failed quote request for asset a.
+// Errors other than ErrTickerNotFound are ignored, since they do not
+// tell us anything about the symbol.
+func (c *SymbolChecker) RecordError(a *Asset, symbol string, err error) {
+	if errors.Is(err, ErrTickerNotFound) {
+		c.record(a, symbol, true)
+	}
+}
+
+// Check checks the Y! Finance symbols of all given assets. It stops at the
+// first network error, which is returned along with the results obtained so far.
+func (c *SymbolChecker) Check(assets []*Asset) ([]*SymbolStatus, error) {
+	var res []*SymbolStatus
+	for _, a := range assets {
+		symbol := a.QuoteServiceSymbols["YF"]
+		if symbol == "" {
+			continue
+		}
+		_, err := c.provider.FetchQuoteSummary(symbol)
+		if err != nil && !errors.Is(err, ErrTickerNotFound) {
+			var connErr *url.Error
+			if errors.As(err, &connErr) {
+				return res, err // Give up on network issues
+			}
+			log.Printf("Failed to check symbol %q of asset %s: %v", symbol, a.ID(), err)
+			continue
+		}
+		res = append(res, c.record(a, symbol, err != nil))
+	}
+	c.mut.Lock()
+	c.lastRun = time.Now()
+	c.mut.Unlock()
+	return res, nil
+}
+
+// LastRun returns the time at which Check was last completed.
+func (c *SymbolChecker) LastRun() time.Time {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.lastRun
+}
+
+// Broken returns the statuses of those assets whose symbols were not found
+// by the latest check, ordered by asset name. Results for symbols that have
+// changed since they were checked are ignored.
+func (c *SymbolChecker) Broken(assets []*Asset) []*SymbolStatus {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	var res []*SymbolStatus
+	for _, a := range assets {
+		st, ok := c.results[a.ID()]
+		if ok && st.NotFound && st.Symbol == a.QuoteServiceSymbols["YF"] {
+			res = append(res, st)
+		}
+	}
+	slices.SortFunc(res, func(a, b *SymbolStatus) int {
+		return strings.Compare(a.AssetName, b.AssetName)
+	})
+	return res
+}
+
+// Run checks the symbols of the assets returned by assets every interval
+// until done is closed. Checks are skipped while assets returns nil.
+func (c *SymbolChecker) Run(assets func() []*Asset, interval time.Duration, done <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if as := assets(); as != nil {
+			if _, err := c.Check(as); err != nil {
+				log.Printf("Symbol check failed: %v", err)
+			}
+		}
+		select {
+		case <-t.C:
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package kontoo
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestSymbolChecker(t *testing.T) {
+	fake := NewFakeQuoteProvider()
+	fake.Timezones["AAPL"] = "America/New_York"
+	assets := []*Asset{
+		{Name: "Apple", TickerSymbol: "AAPL", QuoteServiceSymbols: map[string]string{"YF": "AAPL"}},
+		{Name: "Gone", TickerSymbol: "GONE", QuoteServiceSymbols: map[string]string{"YF": "GONE"}},
+		{Name: "No symbol", TickerSymbol: "NOSYM"},
+	}
+	c := NewSymbolChecker(fake)
+	if !c.LastRun().IsZero() {
+		t.Error("LastRun should be zero before first check")
+	}
+	res, err := c.Check(assets)
+	if err != nil {
+		t.Fatal("Check failed:", err)
+	}
+	if len(res) != 2 {
+		t.Errorf("Wrong number of results: want 2, got %d", len(res))
+	}
+	if c.LastRun().IsZero() {
+		t.Error("LastRun not updated")
+	}
+	broken := c.Broken(assets)
+	if len(broken) != 1 || broken[0].AssetID != "GONE" {
+		t.Fatalf("Wrong broken symbols: %v", broken)
+	}
+	// Once the symbol is fixed, the old result must be ignored.
+	assets[1].QuoteServiceSymbols["YF"] = "GONE.DE"
+	if broken := c.Broken(assets); len(broken) != 0 {
+		t.Errorf("Expected no broken symbols after symbol change, got %v", broken)
+	}
+}
+
+func TestSymbolCheckerNetworkError(t *testing.T) {
+	fake := NewFakeQuoteProvider()
+	fake.Err = &url.Error{Op: "Get", URL: "https://example.com", Err: errors.New("offline")}
+	assets := []*Asset{
+		{Name: "Apple", TickerSymbol: "AAPL", QuoteServiceSymbols: map[string]string{"YF": "AAPL"}},
+	}
+	c := NewSymbolChecker(fake)
+	if _, err := c.Check(assets); err == nil {
+		t.Fatal("Expected error")
+	}
+	// Network errors must not flag symbols as broken.
+	c.RecordError(assets[0], "AAPL", fake.Err)
+	if broken := c.Broken(assets); len(broken) != 0 {
+		t.Errorf("Expected no broken symbols, got %v", broken)
+	}
+}
@@ -91,14 +91,17 @@ func joinAny(items any, sep string) (string, error) {
 	return strings.Join(elems, sep), nil
 }
 
-// Parses the period from a request as a duration.
+// Parses the period from a request and returns the start date of the period ending at end.
 // Valid values are "Max", "YTD", and "<N><Unit>", where <Unit> must be one of
-// "D", "W", "M", "Y".
+// "D", "W", "M", "Y" and N must be positive. This covers the usual chart presets
+// "1M", "3M", "6M", "YTD", "1Y", "5Y", "Max". Values are case-insensitive.
+// For "Max", the zero Date is returned.
 func parsePeriod(end Date, p string) (Date, error) {
 	if p == "" {
 		return Date{}, fmt.Errorf("empty period given")
 	}
-	if p == "Max" {
+	p = strings.ToUpper(p)
+	if p == "MAX" {
 		return Date{}, nil
 	}
 	if p == "YTD" {
@@ -108,6 +111,9 @@ func parsePeriod(end Date, p string) (Date, error) {
 	if err != nil {
 		return Date{}, fmt.Errorf("invalid number in period %s", p)
 	}
+	if n <= 0 {
+		return Date{}, fmt.Errorf("period must be positive: %s", p)
+	}
 	switch p[len(p)-1] {
 	case 'D':
 		return Date{end.AddDate(0, 0, -n)}, nil
@@ -122,6 +128,20 @@ func parsePeriod(end Date, p string) (Date, error) {
 	}
 }
 
+// formatFileSize formats n bytes using binary units, e.g. "1.5 KiB".
+func formatFileSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 func commonFuncs() template.FuncMap {
 	return template.FuncMap{
 		"concat": func(s, t string) string {
@@ -197,6 +217,7 @@ func commonFuncs() template.FuncMap {
 			u.Path = strings.ReplaceAll(u.Path, "{"+pathParam+"}", url.PathEscape(value))
 			return u.String(), nil
 		},
-		"join": joinAny,
+		"join":     joinAny,
+		"filesize": formatFileSize,
 	}
 }
@@ -49,6 +49,14 @@ func TestParsePeriod(t *testing.T) {
 		{DateVal(2024, 1, 1), "Max", Date{}},
 		{DateVal(2023, 7, 31), "YTD", DateVal(2023, 1, 1)},
 		{DateVal(2023, 1, 1), "YTD", DateVal(2023, 1, 1)},
+		{DateVal(2024, 5, 31), "3M", DateVal(2024, 3, 2)},
+		{DateVal(2024, 8, 15), "6M", DateVal(2024, 2, 15)},
+		{DateVal(2024, 8, 15), "5Y", DateVal(2019, 8, 15)},
+		{DateVal(2024, 8, 15), "2W", DateVal(2024, 8, 1)},
+		// Case-insensitive
+		{DateVal(2024, 1, 1), "max", Date{}},
+		{DateVal(2024, 8, 15), "ytd", DateVal(2024, 1, 1)},
+		{DateVal(2024, 8, 15), "1y", DateVal(2023, 8, 15)},
 	}
 	for _, tc := range tests {
 		got, err := parsePeriod(tc.end, tc.period)
@@ -60,3 +68,31 @@ func TestParsePeriod(t *testing.T) {
 		}
 	}
 }
+
+func TestParsePeriodInvalid(t *testing.T) {
+	tests := []string{"", "M", "0M", "-1Y", "1X", "Y1", "1.5Y", "Forever"}
+	for _, p := range tests {
+		if got, err := parsePeriod(DateVal(2024, 1, 1), p); err == nil {
+			t.Errorf("Expected error for period %q, got %v", p, got)
+		}
+	}
+}
+
+func TestFormatFileSize(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 * 1024 * 1024, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+	}
+	for _, tc := range tests {
+		if got := formatFileSize(tc.n); got != tc.want {
+			t.Errorf("formatFileSize(%d): want %q, got %q", tc.n, tc.want, got)
+		}
+	}
+}
@@ -63,12 +63,19 @@ export async function handleQuotesSubmit(e) {
         quotes: [],
         exchangeRates: [],
     };
+    const results = document.getElementById("results");
+    if (results && results.dataset.importSignature) {
+        // Lets the server remember which assets were imported from this file format.
+        request.importSignature = results.dataset.importSignature;
+    }
     inputs.forEach(inp => {
         if (inp.name === "quote") {
             request.quotes.push({
                 assetID: inp.dataset.asset,
                 date: inp.dataset.date,
-                priceMicros: parseInt(inp.dataset.price)
+                priceMicros: parseInt(inp.dataset.price),
+                currency: inp.dataset.currency,
+                convert: inp.dataset.convert === "true",
             });
         } else if (inp.name === "exchangerate") {
             request.exchangeRates.push({
@@ -103,6 +110,31 @@ export async function handleQuotesSubmit(e) {
     }
 }
 
+// Asks the user to confirm the conversion of a quote whose currency
+// differs from the asset's currency. Unchecks the input if not confirmed.
+function confirmCurrencyConversion(inp) {
+    if (!inp.checked) {
+        delete inp.dataset.convert;
+        return;
+    }
+    const d = inp.dataset;
+    const converted = parseInt(d.convertedPrice);
+    if (!converted) {
+        alert(`The quote for ${d.assetName} is in ${d.currency}, but the asset's currency is ${d.assetCurrency}.\n` +
+            `It cannot be converted, because no exchange rate is known.`);
+        inp.checked = false;
+        return;
+    }
+    const price = (parseInt(d.price) / 1e6).toFixed(2);
+    const convertedPrice = (converted / 1e6).toFixed(2);
+    if (confirm(`The quote for ${d.assetName} is in ${d.currency}, but the asset's currency is ${d.assetCurrency}.\n\n` +
+        `Import ${price} ${d.currency} as ${convertedPrice} ${d.assetCurrency}?`)) {
+        inp.dataset.convert = "true";
+    } else {
+        inp.checked = false;
+    }
+}
+
 // Registers event listeners for quotes upload functionality:
 //
 // Expects the following elements to be present in the DOM:
@@ -117,10 +149,20 @@ export function registerQuotesSubmit() {
         selectAll.addEventListener("change", function (e) {
             // Get state of clicked selectAll here, to set inputs all to this value.
             const isChecked = e.target.checked;
-            const inputs = document.querySelectorAll("input.selector");
+            // Quotes with a currency mismatch must be confirmed individually.
+            const inputs = document.querySelectorAll("input.selector:not(.currency-mismatch)");
             inputs.forEach(inp => inp.checked = isChecked);
+            if (!isChecked) {
+                document.querySelectorAll("input.selector.currency-mismatch").forEach(inp => {
+                    inp.checked = false;
+                    delete inp.dataset.convert;
+                });
+            }
         });
     }
+    document.querySelectorAll("input.selector.currency-mismatch").forEach(inp => {
+        inp.addEventListener("change", () => confirmCurrencyConversion(inp));
+    });
     const submit = document.getElementById("submit");
     if (submit) {
         submit.addEventListener("click", handleQuotesSubmit);
@@ -196,3 +238,75 @@ export function registerContextMenu(element, callback) {
         callback(option);
     })
 }
+
+function renderSearchResults(div, hits) {
+    div.replaceChildren();
+    if (hits.length === 0) {
+        const a = document.createElement("a");
+        a.textContent = "No results";
+        div.appendChild(a);
+    }
+    hits.forEach(hit => {
+        const a = document.createElement("a");
+        a.href = hit.url;
+        a.textContent = hit.title;
+        if (hit.detail) {
+            const detail = document.createElement("div");
+            detail.classList.add("search-detail");
+            detail.textContent = hit.detail;
+            a.appendChild(detail);
+        }
+        div.appendChild(a);
+    });
+    div.classList.remove("hidden");
+}
+
+// Registers the site-wide search box in the navigation bar, which
+// shows the results of /kontoo/api/search while the user is typing.
+export function registerSearch() {
+    const input = document.getElementById("search-input");
+    const results = document.getElementById("search-results");
+    if (!input || !results) {
+        return;
+    }
+    let timeout = null;
+    input.addEventListener("input", () => {
+        clearTimeout(timeout);
+        const q = input.value.trim();
+        if (!q) {
+            results.classList.add("hidden");
+            return;
+        }
+        // Wait for the user to stop typing.
+        timeout = setTimeout(async () => {
+            try {
+                const resp = await fetch("/kontoo/api/search?" + new URLSearchParams({ q: q, limit: 10 }));
+                if (!resp.ok) {
+                    throw new Error(`HTTP error! status: ${resp.status}`);
+                }
+                const data = await resp.json();
+                if (data.status === "OK") {
+                    renderSearchResults(results, data.hits);
+                }
+            }
+            catch (error) {
+                console.error("Error on search:", error);
+            }
+        }, 200);
+    });
+    input.addEventListener("keydown", (event) => {
+        if (event.key === "Enter") {
+            // Jump to the best hit.
+            const first = results.querySelector("a[href]");
+            if (first) {
+                window.location.href = first.href;
+            }
+        } else if (event.key === "Escape") {
+            results.classList.add("hidden");
+        }
+    });
+    input.addEventListener("blur", () => {
+        // Delay, so that clicks on results still work.
+        setTimeout(() => results.classList.add("hidden"), 200);
+    });
+}
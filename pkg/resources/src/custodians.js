@@ -0,0 +1,83 @@
+import { callout, calloutError, calloutStatus } from './common';
+
+async function deleteCustodian(custodianId) {
+    try {
+        const response = await fetch("/kontoo/custodians/delete", {
+            method: "POST",
+            body: JSON.stringify({
+                custodianId: custodianId
+            }),
+            headers: {
+                "Content-Type": "application/json"
+            }
+        });
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        const data = await response.json();
+        if (data.status === "OK") {
+            location.reload();
+        } else {
+            calloutStatus(data.status, data.error);
+        }
+    }
+    catch (error) {
+        console.error("Error on delete:", error);
+        calloutError(`Could not delete custodian: ${error}`);
+    }
+}
+
+export function init() {
+    document.querySelectorAll("button.delete").forEach(button => {
+        button.addEventListener("click", () => deleteCustodian(button.dataset.custodianId));
+    });
+    const form = document.querySelector("#custodian-form");
+    form.addEventListener("submit", async function (event) {
+        event.preventDefault(); // Prevent the default form submission
+        const formData = new FormData(this);
+        const custodian = {};
+        const custodianId = formData.get("CustodianId");
+        formData.delete("CustodianId");  // Don't add it as a field to the custodian.
+        formData.forEach((value, key) => {
+            if (!value) {
+                return;
+            }
+            if (key === "Depots") {
+                const depots = value.split(",").map(d => d.trim()).filter(d => d);
+                if (depots.length > 0) {
+                    custodian[key] = depots;
+                }
+            } else {
+                custodian[key] = value;
+            }
+        });
+        try {
+            const response = await fetch("/kontoo/custodians", {
+                method: "POST",
+                body: JSON.stringify({
+                    "custodianId": custodianId,
+                    "custodian": custodian
+                }),
+                headers: {
+                    "Content-Type": "application/json"
+                }
+            });
+            if (!response.ok) {
+                throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            const data = await response.json();
+            if (data.status === "OK") {
+                if (custodianId) {
+                    callout(`Successfully updated custodian ${data.custodianId}.`);
+                } else {
+                    location.reload();
+                }
+            } else {
+                calloutStatus(data.status, data.error);
+            }
+        }
+        catch (error) {
+            console.error("Error on submit:", error);
+        }
+    });
+}
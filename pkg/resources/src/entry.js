@@ -111,7 +111,7 @@ function entryTypeChange(typ) {
     } else if (typ === "AccountBalance" || typ === "AccountDebit" || typ === "AccountCredit") {
         showFields(["AssetID", "Value"]);
     } else if (typ === "AssetHolding") {
-        showFields(["AssetID", "Value", "Quantity", "Price"]);
+        showFields(["AssetID", "Value", "Quantity", "Price", "Depot"]);
     } else if (typ === "InterestPayment" || typ == "DividendPayment") {
         showFields(["AssetID", "Value"]);
     } else if (typ === "AssetPrice") {
@@ -119,13 +119,13 @@ function entryTypeChange(typ) {
     } else if (typ === "AssetMaturity") {
         showFields(["AssetID", "Value"]);
     } else {
-        showFields(["AssetID", "Value", "Quantity", "Price", "Cost"]);
+        showFields(["AssetID", "Value", "Quantity", "Price", "Cost", "Depot"]);
     }
 }
 
 function showFields(fieldNames) {
     const allFieldNames = [
-        "AssetID", "Value", "Quantity", "Price", "Cost", "QuoteCurrency"
+        "AssetID", "Value", "Quantity", "Price", "Cost", "Depot", "QuoteCurrency"
     ];
     for (const fieldName of allFieldNames) {
         if (fieldNames.includes(fieldName)) {
@@ -136,6 +136,24 @@ function showFields(fieldNames) {
     }
 }
 
+async function postEntry(url, update, entry, confirmed) {
+    const response = await fetch(url, {
+        method: "POST",
+        body: JSON.stringify({
+            updateExisting: update,
+            entry: entry,
+            confirmed: confirmed
+        }),
+        headers: {
+            "Content-Type": "application/json"
+        }
+    });
+    if (!response.ok) {
+        throw new Error(`HTTP error! status: ${response.status}`);
+    }
+    return response.json();
+}
+
 async function submitForm(event) {
     event.preventDefault(); // Prevent the default form submission
     const formData = new FormData(this);
@@ -147,26 +165,21 @@ async function submitForm(event) {
         }
         if (key === "SequenceNum") {
             entry[key] = parseInt(value);
+        } else if (key === "Pinned") {
+            entry[key] = true;  // Checkboxes are only present if checked.
         } else {
             entry[key] = value;
         }
     })
     try {
         const update = formData.has("SequenceNum");
-        const response = await fetch(this.action, {
-            method: "POST",
-            body: JSON.stringify({
-                updateExisting: update,
-                entry: entry
-            }),
-            headers: {
-                "Content-Type": "application/json"
+        let data = await postEntry(this.action, update, entry, false);
+        if (data.status === "CONFIRMATION_REQUIRED") {
+            if (!confirm(`${data.error}\n\nSave the entry anyway?`)) {
+                return;
             }
-        });
-        if (!response.ok) {
-            throw new Error(`HTTP error! status: ${response.status}`);
+            data = await postEntry(this.action, update, entry, true);
         }
-        const data = await response.json();
         if (data.status === "OK") {
             const tm = new Date().toLocaleTimeString('en-GB');
             callout(`${tm} - ${update ? "Updated" : "Added"} ledger entry with sequence number ${data.sequenceNum}.`);
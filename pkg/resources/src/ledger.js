@@ -27,6 +27,33 @@ async function deleteLedgerEntry(sequenceNum) {
     }
 }
 
+async function pinLedgerEntry(sequenceNum, pinned) {
+    try {
+        const response = await fetch("/kontoo/entries/pin", {
+            method: "POST",
+            body: JSON.stringify({
+                sequenceNum: sequenceNum,
+                pinned: pinned
+            }),
+            headers: {
+                "Content-Type": "application/json"
+            }
+        });
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        const data = await response.json();
+        if (data.status === "OK") {
+            location.reload();
+        } else {
+            console.error("Could not pin ledger entry:", data.status, data.error);
+        }
+    }
+    catch (error) {
+        console.error("Error on pin:", error);
+    }
+}
+
 async function editLedgerEntry(sequenceNum) {
     console.log(`WIP: Would like to edit entry ${sequenceNum}`);
 }
@@ -50,6 +77,9 @@ function registerTableEventListeners() {
     document.querySelectorAll("button.delete").forEach(button => {
         button.addEventListener("click", () => deleteLedgerEntry(parseInt(button.dataset.seq)));
     });
+    document.querySelectorAll("button.pin").forEach(button => {
+        button.addEventListener("click", () => pinLedgerEntry(parseInt(button.dataset.seq), button.dataset.pinned !== "true"));
+    });
     document.querySelectorAll("button.edit").forEach(button => {
         button.addEventListener("click", () => editLedgerEntry(parseInt(button.dataset.seq)));
     });
@@ -93,6 +123,24 @@ export function init() {
 
     document.getElementById("reload-ledger").addEventListener("click", reloadLedger);
 
+    document.getElementById("shift-dates").addEventListener("click", () => {
+        const url = new URL("/kontoo/entries/shift", window.location.origin);
+        url.searchParams.set("q", document.getElementById("filter").value);
+        window.location.href = url.href;
+    });
+
+    // Grouping is rendered server-side, so we just navigate to the other mode.
+    document.getElementById("toggle-grouping").addEventListener("click", () => {
+        const url = new URL(window.location.href);
+        if (url.searchParams.get("group")) {
+            url.searchParams.delete("group");
+        } else {
+            url.searchParams.set("group", "asset");
+        }
+        url.searchParams.delete("collapsed");
+        window.location.href = url.href;
+    });
+
     registerTableEventListeners();
 
     // Filter query
@@ -3,6 +3,7 @@ import flatpickr from 'flatpickr';
 // that you have to import the CSS, too?!?
 // See https://github.com/flatpickr/flatpickr/issues/141
 import 'flatpickr/dist/flatpickr.min.css';
+import { registerSearch } from './common';
 
 //
 // Global definitions
@@ -44,6 +45,22 @@ async function initCalcPage() {
     const calc = await import('./calc.js');
     calc.init();
 }
+async function initRiskPage() {
+    const risk = await import('./risk.js');
+    risk.init();
+}
+async function initCustodiansPage() {
+    const custodians = await import('./custodians.js');
+    custodians.init();
+}
+async function initShiftPage() {
+    const shift = await import('./shift.js');
+    shift.init();
+}
+async function initTrashPage() {
+    const trash = await import('./trash.js');
+    trash.init();
+}
 
 // Validate that input contains a decimal number with an optional '%' at the end.
 // (I.e., a string that can be JSON-parsed as Micros.)
@@ -66,6 +83,9 @@ function validateMicros(input) {
 // Main code
 //
 
+// Site-wide search box in the navigation bar.
+registerSearch();
+
 // Set up date pickers.
 document.querySelectorAll('.datepicker').forEach(input => {
     flatpickr(input, {
@@ -114,6 +134,22 @@ switch (document.body.id) {
     case "calc-page":
         initCalcPage();
         break;
+    case "risk-page":
+        initRiskPage();
+        break;
+    case "custodians-page":
+        initCustodiansPage();
+        break;
+    case "trash-page":
+        initTrashPage();
+        break;
+    case "shift-page":
+        initShiftPage();
+        break;
+    case "gaps-page":
+    case "stats-page":
+        // No page-specific JS.
+        break;
     default:
         console.error(`Page with body id "${document.body.id}" not handled in main.js`);
         break;
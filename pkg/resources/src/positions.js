@@ -63,7 +63,16 @@ function updateChartPeriod(period) {
     });
 }
 
-function drawTimelines(timelines) {
+function formatDate(timestamp) {
+    return new Date(timestamp).toLocaleDateString("en-GB", {
+        day: "numeric",
+        month: "short",
+        year: "numeric",
+        timeZone: "UTC",
+    });
+}
+
+function drawTimelines(timelines, startTimestamp, endTimestamp) {
     if (!chart) {
         chart = new Chart(
             document.getElementById('positions-canvas'),
@@ -75,6 +84,10 @@ function drawTimelines(timelines) {
                     plugins: {
                         legend: {
                             display: true
+                        },
+                        title: {
+                            display: true,
+                            text: ''
                         }
                     },
                     scales: {
@@ -115,6 +128,10 @@ function drawTimelines(timelines) {
             y: timeline.valueMicros[i] / 1e6
         }))
     }));
+    // Label the chart with the period actually used by the server.
+    chart.options.scales.x.min = startTimestamp;
+    chart.options.scales.x.max = endTimestamp;
+    chart.options.plugins.title.text = `${formatDate(startTimestamp)} – ${formatDate(endTimestamp)}`;
     chart.update('none');
 }
 
@@ -145,7 +162,7 @@ async function fetchAndDrawTimelines() {
             console.log("Response not OK:", result);
             return;
         }
-        drawTimelines(result.timelines);
+        drawTimelines(result.timelines, result.startTimestamp, result.endTimestamp);
         document.getElementById("positions-chart").classList.remove("hidden");
     }
     catch (error) {
@@ -6,6 +6,7 @@ import { enGB } from 'date-fns/locale';
 
 
 let chart = null;
+let interestChart = null;
 
 function followUrl(item) {
     window.location.href = item.dataset.url;
@@ -75,6 +76,93 @@ function drawBarChart(maturities) {
     chart.update('none');
 }
 
+function drawInterestChart(data) {
+    if (!interestChart) {
+        interestChart = new Chart(
+            document.getElementById('interest-canvas'),
+            {
+                type: 'line',
+                data: {},
+                options: {
+                    animation: false,
+                    parsing: false,
+                    scales: {
+                        x: {
+                            type: 'time',
+                            time: {
+                                unit: 'month'
+                            },
+                            adapters: {
+                                date: {
+                                    locale: enGB
+                                }
+                            }
+                        },
+                        y: {
+                            display: true,
+                            title: {
+                                display: true,
+                                text: "%"
+                            }
+                        }
+                    },
+                    plugins: {
+                        legend: {
+                            display: false
+                        },
+                        title: {
+                            display: true,
+                            text: "Weighted average interest (fixed-income and cash)"
+                        }
+                    }
+                },
+            }
+        );
+    }
+    interestChart.data = {
+        datasets: [{
+            label: "Interest",
+            stepped: true,
+            data: data.timestamps.map((t, i) => ({
+                x: t,
+                y: data.interestMicros[i] / 1e4
+            }))
+        }]
+    };
+    interestChart.update('none');
+}
+
+async function fetchAndDrawInterest() {
+    try {
+        const dateParam = new URLSearchParams(window.location.search).get("date");
+        const endTimestamp = dateParam ? new Date(dateParam).getTime() : Date.now();
+        const resp = await fetch("/kontoo/positions/interest", {
+            method: "POST",
+            headers: {
+                "Content-Type": "application/json"
+            },
+            body: JSON.stringify({
+                "endTimestamp": endTimestamp,
+                "period": "5Y",
+            })
+        });
+        if (!resp.ok) {
+            throw new Error(`Server returned status ${resp.status}`);
+        }
+        const result = await resp.json();
+        if (result.status !== "OK") {
+            console.log("Response not OK:", result);
+            return;
+        }
+        drawInterestChart(result);
+        document.getElementById("interest-chart").classList.remove("hidden");
+    }
+    catch (error) {
+        console.error("Error fetching interest history:", error);
+        return;
+    }
+}
+
 async function fetchAndDrawMaturities() {
     try {
         const dateParam = new URLSearchParams(window.location.search).get("date");
@@ -111,9 +199,11 @@ export function init() {
     document.querySelectorAll(".contextmenu.entry-actions").forEach((td) => {
         registerContextMenu(td, contextMenuSelected);
     });
-    const chartDiv = document.querySelector("#maturities-chart");
-    chartDiv.querySelector(".close").addEventListener("click", () => {
-        chartDiv.classList.add("hidden");
+    document.querySelectorAll(".chart-container").forEach((chartDiv) => {
+        chartDiv.querySelector(".close").addEventListener("click", () => {
+            chartDiv.classList.add("hidden");
+        });
     });
     fetchAndDrawMaturities();
+    fetchAndDrawInterest();
 }
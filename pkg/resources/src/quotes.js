@@ -1,5 +1,32 @@
-import { registerQuotesSubmit } from "./common";
+import { calloutStatus, registerQuotesSubmit } from "./common";
+
+async function checkSymbols() {
+    try {
+        const response = await fetch("/kontoo/quotes/symbols/check", {
+            method: "POST",
+            headers: {
+                "Content-Type": "application/json"
+            }
+        });
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        const data = await response.json();
+        if (data.status === "OK") {
+            location.reload();
+        } else {
+            calloutStatus(data.status, data.error);
+        }
+    }
+    catch (error) {
+        console.error("Error checking symbols:", error);
+    }
+}
 
 export function init() {
     registerQuotesSubmit();
-}
\ No newline at end of file
+    const button = document.querySelector("#check-symbols");
+    if (button) {
+        button.addEventListener("click", checkSymbols);
+    }
+}
@@ -0,0 +1,86 @@
+import Chart from 'chart.js/auto';
+
+let chart = null;
+
+function drawBarChart(data) {
+    if (!chart) {
+        chart = new Chart(
+            document.getElementById('risk-canvas'),
+            {
+                type: 'bar',
+                data: {},
+                options: {
+                    animation: false,
+                    scales: {
+                        y: {
+                            display: true,
+                            title: {
+                                display: true,
+                                text: data.currency
+                            }
+                        }
+                    },
+                    plugins: {
+                        legend: {
+                            position: 'top',
+                            display: true
+                        },
+                        title: {
+                            display: true,
+                            text: "Impact by asset category"
+                        }
+                    }
+                },
+            }
+        );
+    }
+    chart.data = {
+        labels: data.categories,
+        datasets: data.scenarios.map((s, i) => ({
+            label: s,
+            data: data.impactMicros[i].map(x => Math.round(x / 1e6))
+        }))
+    }
+    chart.update('none');
+}
+
+async function fetchAndDrawImpacts() {
+    try {
+        const dateParam = new URLSearchParams(window.location.search).get("date");
+        const endTimestamp = dateParam ? new Date(dateParam).getTime() : Date.now();
+        const resp = await fetch("/kontoo/charts/risk", {
+            method: "POST",
+            headers: {
+                "Content-Type": "application/json"
+            },
+            body: JSON.stringify({
+                "endTimestamp": endTimestamp,
+            })
+        });
+        if (!resp.ok) {
+            throw new Error(`Server returned status ${resp.status}`);
+        }
+        const result = await resp.json();
+        if (result.status !== "OK") {
+            console.log("Response not OK:", result);
+            return;
+        }
+        drawBarChart(result);
+        document.getElementById("risk-chart").classList.remove("hidden");
+    }
+    catch (error) {
+        console.error("Error fetching stress test data:", error);
+        return;
+    }
+}
+
+export function init() {
+    const chartDiv = document.querySelector("#risk-chart");
+    if (!chartDiv) {
+        return; // No data to display.
+    }
+    chartDiv.querySelector(".close").addEventListener("click", () => {
+        chartDiv.classList.add("hidden");
+    });
+    fetchAndDrawImpacts();
+}
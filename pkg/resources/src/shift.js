@@ -0,0 +1,48 @@
+import { calloutError, calloutStatus } from './common';
+
+async function postShiftAction(url, request) {
+    try {
+        const response = await fetch(url, {
+            method: "POST",
+            body: JSON.stringify(request),
+            headers: {
+                "Content-Type": "application/json"
+            }
+        });
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        const data = await response.json();
+        if (data.status === "OK") {
+            // Drop the preview parameters, the entries were shifted already.
+            window.location.href = window.location.pathname;
+        } else {
+            calloutStatus(data.status, data.error);
+        }
+    }
+    catch (error) {
+        console.error("Error shifting dates:", error);
+        calloutError(`Could not shift dates: ${error}`);
+    }
+}
+
+export function init() {
+    const apply = document.getElementById("apply-shift");
+    if (apply) {
+        apply.addEventListener("click", () => {
+            const n = apply.dataset.count;
+            if (confirm(`Shift the value dates of ${n} entries by ${apply.dataset.days} days?`)) {
+                postShiftAction("/kontoo/entries/shift", {
+                    query: apply.dataset.query,
+                    days: parseInt(apply.dataset.days)
+                });
+            }
+        });
+    }
+    const undo = document.getElementById("undo-shift");
+    if (undo) {
+        undo.addEventListener("click", () => {
+            postShiftAction("/kontoo/entries/shift/undo", {});
+        });
+    }
+}
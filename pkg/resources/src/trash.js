@@ -0,0 +1,49 @@
+import { calloutError, calloutStatus } from './common';
+
+async function postTrashAction(action, request) {
+    try {
+        const response = await fetch(`/kontoo/trash/${action}`, {
+            method: "POST",
+            body: JSON.stringify(request),
+            headers: {
+                "Content-Type": "application/json"
+            }
+        });
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        const data = await response.json();
+        if (data.status === "OK") {
+            location.reload();
+        } else {
+            calloutStatus(data.status, data.error);
+        }
+    }
+    catch (error) {
+        console.error(`Error on ${action}:`, error);
+        calloutError(`Could not ${action} entry: ${error}`);
+    }
+}
+
+export function init() {
+    document.querySelectorAll("button.restore").forEach(button => {
+        button.addEventListener("click", () => postTrashAction("restore", {
+            sequenceNum: parseInt(button.dataset.seq)
+        }));
+    });
+    document.querySelectorAll("button.purge").forEach(button => {
+        button.addEventListener("click", () => {
+            if (confirm(`Permanently delete entry #${button.dataset.seq}?`)) {
+                postTrashAction("purge", { sequenceNum: parseInt(button.dataset.seq) });
+            }
+        });
+    });
+    const purgeAll = document.getElementById("purge-all");
+    if (purgeAll) {
+        purgeAll.addEventListener("click", () => {
+            if (confirm("Permanently delete all entries in the trash?")) {
+                postTrashAction("purge", { all: true });
+            }
+        });
+    }
+}
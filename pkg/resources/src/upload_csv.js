@@ -21,9 +21,44 @@ export function init() {
     // Handle dropped files
     dropArea.addEventListener('drop', handleDrop, false);
 
+    const settings = document.getElementById("import-settings");
+    document.getElementById("toggle-settings").addEventListener("click", () => {
+        settings.classList.toggle("hidden");
+    });
+    // Only explicitly changed settings are sent to the server.
+    // Otherwise it uses the settings remembered for the file's format.
+    let settingsChanged = false;
+    settings.addEventListener("change", () => settingsChanged = true);
+
+    function columnHeaderInputs() {
+        return [...settings.querySelectorAll("input.column-header")];
+    }
+
+    function showProfile(profile) {
+        document.getElementById("encoding").value = profile.Encoding || "";
+        const columns = profile.Columns;
+        if (columns) {
+            columnHeaderInputs().forEach(inp => {
+                const header = Object.keys(columns).find(h => columns[h] === inp.dataset.field);
+                inp.value = header || "";
+            });
+        }
+        settingsChanged = false;
+    }
+
     async function uploadFiles(files) {
         const formData = new FormData();
         files.forEach(file => formData.append("file", file));
+        if (settingsChanged) {
+            formData.append("encoding", document.getElementById("encoding").value);
+            const columns = {};
+            columnHeaderInputs().forEach(inp => {
+                if (inp.value) {
+                    columns[inp.value] = inp.dataset.field;
+                }
+            });
+            formData.append("columns", JSON.stringify(columns));
+        }
         try {
             const resp = await fetch("/kontoo/csv", {
                 method: "POST",
@@ -48,8 +83,12 @@ export function init() {
             div.innerHTML = data.innerHTML;
             registerQuotesSubmit();
         }
+        if (data.importProfile) {
+            showProfile(data.importProfile);
+        }
         if (data.status === "OK") {
-            callout(`Successfully read ${data.numEntries} rows.`);
+            const known = data.knownFormat ? " using saved import settings" : "";
+            callout(`Successfully read ${data.numEntries} rows${known}.`);
         } else {
             calloutStatus(data.status, data.error);
         }